@@ -0,0 +1,173 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+)
+
+// symbol sent after the escape code at the end of an adaptive stream
+const endOfStream int = 256
+
+// number of bits of a symbol sent after the escape code
+const escapeBits uint8 = 9
+
+// largest number of nodes of an adaptive tree: 257 symbol leaves and the
+// escape leaf, 257 internal nodes
+const maxAdaptiveNodes int = 2*(endOfStream+2) - 1
+
+var errInvalidSymbol = errors.New("huffman: invalid symbol after escape code")
+
+// adaptiveNode is a node of an adaptiveTree. Nodes are numbered by their
+// index in the tree: parent, left and right are indexes, -1 for none.
+type adaptiveNode struct {
+	weight      uint64
+	parent      int
+	left, right int
+	symbol      int // -1 for internal nodes
+}
+
+// adaptiveTree is a Huffman tree updated after each symbol with the FGK
+// algorithm, so that an encoder and a decoder seeing the same symbols keep
+// the same tree without ever storing it.
+//
+// The tree starts as a single escape leaf of weight 0, standing for every
+// byte not seen yet: a new byte is sent as the code of the escape leaf
+// followed by the byte on 9 bits, and the escape leaf is split into a new
+// escape leaf and a leaf for the byte. The end of the stream is sent the
+// same way, as the symbol 256.
+//
+// Nodes are numbered so that weights never decrease with the number and
+// siblings are next to each other, the root having the highest number.
+// Before the weight of a node is incremented it's swapped with the highest
+// numbered node of the same weight, which keeps this ordering and so keeps
+// the tree a Huffman tree of the symbols seen so far.
+type adaptiveTree struct {
+	nodes  []adaptiveNode
+	leaves [endOfStream + 1]int // node of each symbol, -1 for symbols not seen yet
+	escape int                  // node of the escape leaf
+	path   []uint8              // scratch space for the code of a symbol
+}
+
+func newAdaptiveTree() *adaptiveTree {
+	t := &adaptiveTree{nodes: make([]adaptiveNode, maxAdaptiveNodes)}
+	for i := range t.leaves {
+		t.leaves[i] = -1
+	}
+	t.escape = maxAdaptiveNodes - 1
+	t.nodes[t.escape] = adaptiveNode{parent: -1, left: -1, right: -1, symbol: -1}
+	return t
+}
+
+// encode writes the code of symbol and updates the tree.
+func (t *adaptiveTree) encode(w *BitWriter, symbol int) {
+	node := t.leaves[symbol]
+	if node < 0 {
+		node = t.escape
+	}
+
+	// the code is the path from the root, collected from the leaf upward
+	t.path = t.path[:0]
+	for node != len(t.nodes)-1 {
+		parent := t.nodes[node].parent
+		if t.nodes[parent].right == node {
+			t.path = append(t.path, 1)
+		} else {
+			t.path = append(t.path, 0)
+		}
+		node = parent
+	}
+	for i := len(t.path) - 1; i >= 0; i-- {
+		w.WriteBit(t.path[i])
+	}
+
+	if t.leaves[symbol] < 0 {
+		w.WriteBits(uint64(symbol), escapeBits)
+	}
+	t.update(symbol)
+}
+
+// decode reads the code of a symbol and updates the tree.
+func (t *adaptiveTree) decode(r *BitReader) (int, error) {
+	node := len(t.nodes) - 1
+	for t.nodes[node].left >= 0 {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			node = t.nodes[node].right
+		} else {
+			node = t.nodes[node].left
+		}
+	}
+
+	symbol := t.nodes[node].symbol
+	if node == t.escape {
+		value, err := r.ReadBits(escapeBits)
+		if err != nil {
+			return 0, err
+		}
+		symbol = int(value)
+		if symbol > endOfStream || t.leaves[symbol] >= 0 {
+			return 0, fmt.Errorf("%w: %d", errInvalidSymbol, symbol)
+		}
+	}
+	t.update(symbol)
+	return symbol, nil
+}
+
+// update counts one more occurence of symbol.
+func (t *adaptiveTree) update(symbol int) {
+	node := t.leaves[symbol]
+	if node < 0 {
+		node = t.split(symbol)
+	}
+
+	for node >= 0 {
+		leader := node
+		for leader+1 < len(t.nodes) && t.nodes[leader+1].weight == t.nodes[node].weight {
+			leader++
+		}
+		if leader != node && leader != t.nodes[node].parent {
+			t.swap(node, leader)
+			node = leader
+		}
+		t.nodes[node].weight++
+		node = t.nodes[node].parent
+	}
+}
+
+// split turns the escape leaf into an internal node whose children are a new
+// escape leaf and a leaf for symbol. Returns the leaf of symbol.
+func (t *adaptiveTree) split(symbol int) int {
+	parent := t.escape
+	leaf, escape := parent-1, parent-2
+
+	t.nodes[parent].left = escape
+	t.nodes[parent].right = leaf
+	t.nodes[leaf] = adaptiveNode{parent: parent, left: -1, right: -1, symbol: symbol}
+	t.nodes[escape] = adaptiveNode{parent: parent, left: -1, right: -1, symbol: -1}
+
+	t.leaves[symbol] = leaf
+	t.escape = escape
+	return leaf
+}
+
+// swap exchanges the subtrees numbered a and b, neither being an ancestor of
+// the other. Parents stay where they are: the subtrees are exchanged.
+func (t *adaptiveTree) swap(a, b int) {
+	t.nodes[a], t.nodes[b] = t.nodes[b], t.nodes[a]
+	t.nodes[a].parent, t.nodes[b].parent = t.nodes[b].parent, t.nodes[a].parent
+
+	for _, node := range [2]int{a, b} {
+		n := t.nodes[node]
+		if n.left >= 0 {
+			t.nodes[n.left].parent = node
+			t.nodes[n.right].parent = node
+		} else if n.symbol >= 0 {
+			t.leaves[n.symbol] = node
+		} else {
+			t.escape = node
+		}
+	}
+}
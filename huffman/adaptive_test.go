@@ -0,0 +1,189 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// checkSiblingProperty checks that the weights of the nodes of t never
+// decrease with their number, that siblings are numbered next to each other
+// and that internal nodes weigh as much as their children.
+func checkSiblingProperty(t *adaptiveTree) bool {
+	root := len(t.nodes) - 1
+	for node := t.escape; node < root; node++ {
+		n := t.nodes[node]
+		if n.weight > t.nodes[node+1].weight {
+			return false
+		}
+		parent := t.nodes[n.parent]
+		if (node-t.escape)%2 == 0 && (parent.left != node || parent.right != node+1) {
+			return false
+		}
+	}
+	for node := t.escape; node <= root; node++ {
+		n := t.nodes[node]
+		if n.left >= 0 && n.weight != t.nodes[n.left].weight+t.nodes[n.right].weight {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAdaptiveTree(t *testing.T) {
+	data := append([]byte("huffman coding and decoding in golang"), randomData(5000, 256, 7)...)
+	tree := newAdaptiveTree()
+
+	for i, b := range data {
+		tree.update(int(b))
+		if !checkSiblingProperty(tree) {
+			t.Fatalf("Test Adaptive Tree Failed. sibling property broken after %d symbols", i+1)
+		}
+	}
+	if tree.nodes[len(tree.nodes)-1].weight != uint64(len(data)) {
+		t.Fatalf(`Test Adaptive Tree Failed.
+		Got: root weight %d,
+		Wanted: %d`, tree.nodes[len(tree.nodes)-1].weight, len(data))
+	}
+}
+
+func TestAdaptiveRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	all_bytes := make([]byte, 256)
+	for i := range all_bytes {
+		all_bytes[i] = byte(i)
+	}
+
+	test_cases := []test_case{
+		{
+			description: "empty",
+			data:        []byte{},
+		},
+		{
+			description: "single byte",
+			data:        []byte("a"),
+		},
+		{
+			description: "single repeating character",
+			data:        []byte("aaaaaaa"),
+		},
+		{
+			description: "text",
+			data:        []byte("huffman coding and decoding in golang"),
+		},
+		{
+			description: "all bytes",
+			data:        append(all_bytes, all_bytes...),
+		},
+		{
+			description: "binary data bigger than the read and write chunks",
+			data:        randomData(200_000, 64, 8),
+		},
+		{
+			description: "long codes",
+			data:        fibonacciData(20),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := Encoder{Adaptive: true}
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Flags&FlagAdaptive == 0 {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, header %+v,
+				Wanted: %d bytes, <nil>, adaptive header`, scenarioIdx, len(decoded), err, zr.Header(), len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestAdaptiveWriterFlush(t *testing.T) {
+	var encoded bytes.Buffer
+	zw := (&Encoder{Adaptive: true}).NewWriter(&encoded)
+
+	// input is encoded as it's written, without waiting for a block
+	zw.Write(bytes.Repeat([]byte("live stream "), 10))
+	zw.Flush()
+	flushed := encoded.Len()
+	if flushed <= headerSize {
+		t.Fatalf(`Test Adaptive Flush Failed.
+		Got: %d bytes written after Flush,
+		Wanted: more than the %d bytes of the header`, flushed, headerSize)
+	}
+
+	zw.Write([]byte("more"))
+	zw.Close()
+
+	decoded, err := io.ReadAll(NewReader(&encoded))
+	expected := append(bytes.Repeat([]byte("live stream "), 10), "more"...)
+	if err != nil || !bytes.Equal(decoded, expected) {
+		t.Fatalf(`Test Adaptive Flush Failed.
+		Got: %q, %v,
+		Wanted: %q, <nil>`, decoded, err, expected)
+	}
+}
+
+func TestAdaptive_ShouldFail(t *testing.T) {
+	var encoded bytes.Buffer
+	(&Encoder{Adaptive: true}).Encode(&encoded, []byte("huffman coding and decoding in golang"))
+
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "missing trailer",
+			data:        encoded.Bytes()[:encoded.Len()-trailerSize],
+		},
+		{
+			description: "missing end of stream",
+			data:        encoded.Bytes()[:encoded.Len()-trailerSize-4],
+		},
+		{
+			description: "flipped bit in the bitstream",
+			data: func() []byte {
+				tampered := append([]byte{}, encoded.Bytes()...)
+				tampered[headerSize+10] ^= 0b0000_1000
+				return tampered
+			}(),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			_, err := io.ReadAll(NewReader(bytes.NewReader(scenario.data)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeAdaptive(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			b.SetBytes(int64(len(corpus.data)))
+			for i := 0; i < b.N; i++ {
+				(&Encoder{Adaptive: true}).Encode(io.Discard, corpus.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Coder identifies the entropy coder of the blocks of a stream.
+type Coder uint8
+
+const (
+	// CoderHuffman codes blocks with canonical Huffman codes, see
+	// HuffmanCoder.
+	CoderHuffman Coder = iota
+
+	// CoderRange codes blocks with a range coder, see RangeCoder.
+	CoderRange
+
+	// CoderRANS codes blocks with a range asymmetric numeral system coder,
+	// see RANSCoder.
+	CoderRANS
+)
+
+func (c Coder) String() string {
+	switch c {
+	case CoderHuffman:
+		return "huffman"
+	case CoderRange:
+		return "range"
+	case CoderRANS:
+		return "rans"
+	}
+	return fmt.Sprintf("Coder(%d)", uint8(c))
+}
+
+// EntropyCoder codes the bytes of a block with a model of the block,
+// stored at the start of the encoding.
+type EntropyCoder interface {
+	// Encode writes the encoding of data to w.
+	// Returns the number of bytes written to w.
+	Encode(w io.Writer, data []byte) (int, error)
+
+	// Decode reads the encoding of a block of n bytes from r.
+	Decode(r io.Reader, n int) ([]byte, error)
+}
+
+// EntropyCoder returns the coder identified by c, nil if c is unknown.
+// CoderHuffman gives a HuffmanCoder without a code length limit.
+func (c Coder) EntropyCoder() EntropyCoder {
+	switch c {
+	case CoderHuffman:
+		return &HuffmanCoder{}
+	case CoderRange:
+		return RangeCoder{}
+	case CoderRANS:
+		return RANSCoder{}
+	}
+	return nil
+}
+
+// HuffmanCoder codes bytes with the canonical Huffman codes of the block,
+// stored as a code lengths table. The encoding is padded to a whole byte.
+//
+// HuffmanCoder is the backend of CoderHuffman streams: Writer and Reader code
+// their plain blocks with it, the other modes of Huffman coding having
+// layouts of their own.
+type HuffmanCoder struct {
+	// MaxCodeLength caps the length of codes, zero for no limit.
+	MaxCodeLength int
+
+	tree  *Node
+	codes CodeTable
+}
+
+func (c *HuffmanCoder) Encode(w io.Writer, data []byte) (int, error) {
+	if err := c.build(data); err != nil {
+		return 0, err
+	}
+	bw := BitWriter{io_writer: w}
+	bw.WriteCodeLengths(c.codes.Lengths())
+	for _, b := range data {
+		bw.WriteCode(c.codes[b])
+	}
+	return bw.FlushPadded()
+}
+
+// build builds the tree and the canonical codes of data.
+func (c *HuffmanCoder) build(data []byte) error {
+	c.tree = BuildTree(data)
+	lengths := CodeLengths(c.tree)
+	if c.MaxCodeLength != 0 && longestCode(lengths) > c.MaxCodeLength {
+		var err error
+		if lengths, err = LimitedCodeLengths(byteFrequencies(data), c.MaxCodeLength); err != nil {
+			return err
+		}
+		c.tree, _ = treeFromCodeLengths(lengths)
+	}
+	c.codes = NewCanonicalCodeTable(lengths)
+
+	if __DEBUG__ {
+		c.tree.Display(0)
+	}
+	return nil
+}
+
+func (c *HuffmanCoder) Decode(r io.Reader, n int) ([]byte, error) {
+	br := NewPaddedBitReader(r)
+	lengths, err := br.ReadCodeLengths()
+	if err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	if c.tree, err = treeFromCodeLengths(lengths); err != nil {
+		return nil, err
+	}
+	c.codes = NewCanonicalCodeTable(lengths)
+
+	table := newDecodeTable(c.tree)
+	data := make([]byte, n)
+	for i := range data {
+		if data[i], err = table.decode(br); err != nil {
+			return nil, unexpected(err)
+		}
+	}
+	return data, nil
+}
+
+// number of bits of the total of the frequencies of a model, and the
+// total
+const (
+	modelBits  uint8  = 14
+	modelTotal uint32 = 1 << modelBits
+)
+
+// model holds the frequency of each byte scaled to a total of modelTotal,
+// every byte of the block keeping a frequency of at least 1, and the
+// cumulative frequency of the bytes before it.
+type model struct {
+	freqs  [256]uint32
+	starts [256]uint32
+}
+
+// newModel scales the frequencies of the bytes of data.
+func newModel(data []byte) *model {
+	m := &model{}
+	counts := byteFrequencies(data)
+	var total uint32
+	largest := 0
+	for b, count := range counts {
+		if count == 0 {
+			continue
+		}
+		m.freqs[b] = max(uint32(uint64(count)*uint64(modelTotal)/uint64(len(data))), 1)
+		total += m.freqs[b]
+		if m.freqs[b] > m.freqs[largest] {
+			largest = b
+		}
+	}
+	// rounding errors go to the most frequent byte, which they cost the
+	// least
+	m.freqs[largest] += modelTotal - total
+	m.cumulate()
+	return m
+}
+
+func (m *model) cumulate() {
+	var start uint32
+	for b, freq := range m.freqs {
+		m.starts[b] = start
+		start += freq
+	}
+}
+
+// write stores the frequencies as a bitmap of the bytes of the block, 32
+// bytes, followed by the frequency of each of them as a varint.
+func (m *model) write(w io.Writer) (int, error) {
+	buf := make([]byte, 32)
+	for b, freq := range m.freqs {
+		if freq != 0 {
+			buf[b/8] |= 1 << (b % 8)
+		}
+	}
+	for _, freq := range m.freqs {
+		if freq != 0 {
+			buf = binary.AppendUvarint(buf, uint64(freq))
+		}
+	}
+	return w.Write(buf)
+}
+
+var errModel = errors.New("huffman: invalid model")
+
+func readModel(r io.ByteReader) (*model, error) {
+	m := &model{}
+	var present [32]byte
+	for i := range present {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("error reading model %w", unexpected(err))
+		}
+		present[i] = b
+	}
+
+	var total uint64
+	for b := range m.freqs {
+		if present[b/8]&(1<<(b%8)) == 0 {
+			continue
+		}
+		freq, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading model %w", unexpected(err))
+		}
+		if freq == 0 {
+			return nil, fmt.Errorf("%w: zero frequency of byte %d", errModel, b)
+		}
+		total += freq
+		if total > uint64(modelTotal) {
+			return nil, fmt.Errorf("%w: frequencies exceed %d", errModel, modelTotal)
+		}
+		m.freqs[b] = uint32(freq)
+	}
+	if total != uint64(modelTotal) {
+		return nil, fmt.Errorf("%w: frequencies add up to %d instead of %d", errModel, total, modelTotal)
+	}
+	m.cumulate()
+	return m, nil
+}
+
+// symbols returns the byte of each cumulative frequency, for decoding.
+func (m *model) symbols() []byte {
+	symbols := make([]byte, modelTotal)
+	for b, freq := range m.freqs {
+		for i := m.starts[b]; i < m.starts[b]+freq; i++ {
+			symbols[i] = byte(b)
+		}
+	}
+	return symbols
+}
+
+// byteReader returns r as an io.ByteReader.
+func byteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
@@ -0,0 +1,243 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+var coders = []Coder{CoderHuffman, CoderRange, CoderRANS}
+
+func TestEntropyCoders(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single byte",
+			data:        []byte("a"),
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 100_000),
+		},
+		{
+			description: "all bytes",
+			data:        randomData(100_000, 256, 34),
+		},
+		{
+			description: "deep tree",
+			data:        fibonacciData(20),
+		},
+		{
+			description: "source text",
+			data:        sourceText(),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, coder := range coders {
+				var encoded bytes.Buffer
+				n, err := coder.EntropyCoder().Encode(&encoded, scenario.data)
+				if err != nil || n != encoded.Len() {
+					t.Fatalf("Test %d Failed. %v encode: %d bytes written, %d counted, %v", scenarioIdx, coder, encoded.Len(), n, err)
+				}
+
+				decoded, err := coder.EntropyCoder().Decode(iotest.OneByteReader(&encoded), len(scenario.data))
+
+				if err != nil || !bytes.Equal(decoded, scenario.data) {
+					t.Fatalf(`Test %d Failed. %v
+					Got: %d bytes, %v,
+					Wanted: %d bytes, <nil>`, scenarioIdx, coder, len(decoded), err, len(scenario.data))
+				}
+			}
+		})
+	}
+}
+
+func TestHuffmanCoderBlock(t *testing.T) {
+	data := sourceText()[:MinBlockSize]
+	var encoded bytes.Buffer
+	e := Encoder{MaxCodeLength: MinCodeLength}
+	e.Encode(&encoded, data)
+
+	// the body of the only block, after its size and symbol count
+	body := encoded.Bytes()[headerSize+4:]
+	count, n := binary.Uvarint(body)
+	c := HuffmanCoder{MaxCodeLength: MinCodeLength}
+	decoded, err := c.Decode(bytes.NewReader(body[n:]), int(count))
+
+	if err != nil || !bytes.Equal(decoded, data) || c.codes != e.Codes() {
+		t.Fatalf(`Test Failed.
+		Got: %d bytes, %v,
+		Wanted: %d bytes, <nil>, the codes of the Encoder`, len(decoded), err, len(data))
+	}
+}
+
+func TestCoderRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "several blocks",
+			data:        randomData(3*MinBlockSize+17, 64, 35),
+			encoder:     Encoder{BlockSize: MinBlockSize},
+		},
+		{
+			description: "transforms",
+			data:        sourceText(),
+			encoder:     Encoder{Transforms: bzip2Transforms},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, coder := range coders {
+				var encoded bytes.Buffer
+				e := scenario.encoder
+				e.Coder = coder
+				if _, err := e.Encode(&encoded, scenario.data); err != nil {
+					t.Fatalf("Test %d Failed. %v encode error: %v", scenarioIdx, coder, err)
+				}
+
+				zr := NewReader(iotest.HalfReader(&encoded))
+				decoded, err := io.ReadAll(zr)
+
+				if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Coder() != coder {
+					t.Fatalf(`Test %d Failed.
+					Got: %d bytes, %v, coder %v,
+					Wanted: %d bytes, <nil>, coder %v`, scenarioIdx, len(decoded), err, zr.Coder(), len(scenario.data), coder)
+				}
+			}
+		})
+	}
+}
+
+func TestCoderGain(t *testing.T) {
+	// Huffman codes take at least a bit per byte, however frequent
+	data := append(bytes.Repeat([]byte("a"), 1<<20), randomData(1<<14, 256, 36)...)
+	huffman, _ := (&Encoder{}).Encode(io.Discard, data)
+	for _, coder := range coders[1:] {
+		size, _ := (&Encoder{Coder: coder}).Encode(io.Discard, data)
+		t.Logf("%d bytes: Huffman %d bytes, %v %d bytes (%.02f%%)", len(data), huffman, coder, size, 100*float64(size)/float64(huffman))
+
+		if size >= huffman/2 {
+			t.Fatalf(`Test %v Gain Failed.
+			Got: %d bytes,
+			Wanted: less than half of Huffman %d bytes`, coder, size, huffman)
+		}
+	}
+}
+
+func TestCoder_ShouldFail(t *testing.T) {
+	for _, e := range []Encoder{
+		{Coder: CoderRANS + 1},
+		{Coder: CoderRange, Order1: true},
+		{Coder: CoderRANS, Level: 1},
+		{Coder: CoderRange, Format: FormatGzip},
+	} {
+		if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with %+v: got err <nil>, wanted err != <nil>", e)
+		}
+	}
+
+	data := sourceText()[:10_000]
+	for _, coder := range coders[1:] {
+		var encoded bytes.Buffer
+		(&Encoder{Coder: coder}).Encode(&encoded, data)
+
+		// the model follows the block size and the symbol count, the
+		// frequencies following a bitmap of 32 bytes
+		model_idx := headerSize + 1 + 4 + 2 + 32
+
+		type test_case struct {
+			description string
+			tamper      func(encoded []byte) []byte
+		}
+
+		test_cases := []test_case{
+			{
+				description: "huffman coder byte",
+				tamper: func(encoded []byte) []byte {
+					encoded[headerSize] = byte(CoderHuffman)
+					return encoded
+				},
+			},
+			{
+				description: "unknown coder",
+				tamper: func(encoded []byte) []byte {
+					encoded[headerSize] = 9
+					return encoded
+				},
+			},
+			{
+				description: "model total",
+				tamper: func(encoded []byte) []byte {
+					encoded[model_idx] ^= 1
+					return encoded
+				},
+			},
+			{
+				description: "truncated block",
+				tamper: func(encoded []byte) []byte {
+					size := len(encoded) - headerSize - 1 - 8 - 4 - trailerSize
+					encoded[headerSize+1+3] -= byte(min(size/2, 100))
+					return encoded
+				},
+			},
+			{
+				description: "flipped code bits",
+				tamper: func(encoded []byte) []byte {
+					encoded[len(encoded)-trailerSize-4-20] ^= 0x55
+					return encoded
+				},
+			},
+		}
+
+		for scenarioIdx, scenario := range test_cases {
+			t.Run(coder.String()+" "+scenario.description, func(t *testing.T) {
+				tampered := scenario.tamper(append([]byte{}, encoded.Bytes()...))
+
+				decoded, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+				if err == nil && bytes.Equal(decoded, data) {
+					t.Fatalf(`Test %d Failed.
+					Got: err <nil>,
+					Wanted: err != <nil>`, scenarioIdx)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCoders(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		for _, coder := range coders {
+			var encoded bytes.Buffer
+			size, _ := coder.EntropyCoder().Encode(&encoded, corpus.data)
+
+			b.Run(corpus.name+"/"+coder.String()+"/encode", func(b *testing.B) {
+				b.SetBytes(int64(len(corpus.data)))
+				for i := 0; i < b.N; i++ {
+					coder.EntropyCoder().Encode(io.Discard, corpus.data)
+				}
+				b.ReportMetric(float64(size)/float64(len(corpus.data)), "size/input")
+			})
+			b.Run(corpus.name+"/"+coder.String()+"/decode", func(b *testing.B) {
+				b.SetBytes(int64(len(corpus.data)))
+				for i := 0; i < b.N; i++ {
+					coder.EntropyCoder().Decode(bytes.NewReader(encoded.Bytes()), len(corpus.data))
+				}
+			})
+		}
+	}
+}
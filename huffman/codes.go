@@ -0,0 +1,166 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// longest code length a code lengths table can hold
+const maxCodeLength int = 63
+
+// Code is a code of Length bits, held in the lowest bits of Value.
+type Code struct {
+	Value  uint64
+	Length uint8
+}
+
+// CodeTable holds the code of each byte, bytes without a code have a zero Length.
+type CodeTable [256]Code
+
+// Lengths returns the code length of every byte, 0 for bytes without a code.
+func (c CodeTable) Lengths() [256]uint8 {
+	var lengths [256]uint8
+	for b, code := range c {
+		lengths[b] = code.Length
+	}
+	return lengths
+}
+
+// NewCodeTable assigns canonical codes to the leaves of root: only the depth
+// of each leaf is kept from the tree, codes of the same length are
+// consecutive integers in increasing symbol order, and shorter codes come
+// first. The code lengths are then enough to rebuild the same table.
+func NewCodeTable(root *Node) CodeTable {
+	return NewCanonicalCodeTable(CodeLengths(root))
+}
+
+// CodeLengths returns the depth of the leaf of every byte in the tree.
+// The leaf of a single-noded tree gets a 1-bit code.
+func CodeLengths(root *Node) [256]uint8 {
+	var lengths [256]uint8
+	if root != nil && root.IsLeaf() {
+		lengths[root.ch] = 1
+		return lengths
+	}
+
+	var walk func(n *Node, depth uint8)
+	walk = func(n *Node, depth uint8) {
+		if n == nil {
+			return
+		}
+		if n.IsLeaf() {
+			lengths[n.ch] = depth
+			return
+		}
+		walk(n.Left, depth+1)
+		walk(n.Right, depth+1)
+	}
+	walk(root, 0)
+	return lengths
+}
+
+// NewCanonicalCodeTable assigns canonical codes from code lengths.
+func NewCanonicalCodeTable(lengths [256]uint8) CodeTable {
+	var codes CodeTable
+	copy(codes[:], canonicalCodes(lengths[:]))
+	return codes
+}
+
+// canonicalCodes assigns canonical codes from the code lengths of an
+// alphabet of len(lengths) symbols.
+func canonicalCodes(lengths []uint8) []Code {
+	symbols := make([]int, 0, len(lengths))
+	for symbol, length := range lengths {
+		if length != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return lengths[symbols[i]] < lengths[symbols[j]]
+	})
+
+	codes := make([]Code, len(lengths))
+	var code uint64
+	var prev_length uint8
+	for i, symbol := range symbols {
+		length := lengths[symbol]
+		if i > 0 {
+			code = (code + 1) << (length - prev_length)
+		}
+		prev_length = length
+
+		codes[symbol] = Code{Value: code, Length: length}
+		if __DEBUG__ {
+			fmt.Printf("code(%d)=%0*b\n", symbol, length, code)
+		}
+	}
+	return codes
+}
+
+// checkCodeLengths verifies that codes of these lengths can be told apart.
+func checkCodeLengths(lengths []uint8) error {
+	// sum of 2^-length over all codes, scaled by 2^maxCodeLength
+	var kraft uint64
+	used := 0
+	for _, length := range lengths {
+		if int(length) > maxCodeLength {
+			return fmt.Errorf("huffman: code length %d exceeds %d", length, maxCodeLength)
+		}
+		if length != 0 {
+			kraft += 1 << (maxCodeLength - int(length))
+			used++
+		}
+		if kraft > 1<<maxCodeLength {
+			return errors.New("huffman: code lengths table is oversubscribed")
+		}
+	}
+	if used == 0 {
+		return errors.New("huffman: empty code lengths table")
+	}
+	return nil
+}
+
+// treeFromCodeLengths rebuilds the tree of the canonical codes of lengths.
+// Returns a single leaf for a table with one 1-bit code, like BuildTree does
+// for data with one distinct byte.
+func treeFromCodeLengths(lengths [256]uint8) (*Node, error) {
+	if err := checkCodeLengths(lengths[:]); err != nil {
+		return nil, err
+	}
+
+	codes := NewCanonicalCodeTable(lengths)
+	used := 0
+	for _, code := range codes {
+		if code.Length != 0 {
+			used++
+		}
+	}
+	if used == 1 {
+		for b, code := range codes {
+			if code.Length != 0 {
+				return &Node{ch: byte(b)}, nil
+			}
+		}
+	}
+
+	root := &Node{}
+	for b, code := range codes {
+		if code.Length == 0 {
+			continue
+		}
+		current := root
+		for i := int(code.Length) - 1; i >= 0; i-- {
+			next := &current.Left
+			if code.Value>>i&1 == 1 {
+				next = &current.Right
+			}
+			if *next == nil {
+				*next = &Node{}
+			}
+			current = *next
+		}
+		current.ch = byte(b)
+	}
+	return root, nil
+}
@@ -0,0 +1,111 @@
+package huffman
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewCodeTable(t *testing.T) {
+	codes := NewCodeTable(MOCK_TREE)
+
+	// C, A and E are 2 bits long, D and B 3 bits, codes of the same length
+	// are in increasing byte order
+	var expected_codes CodeTable
+	expected_codes['A'] = Code{Value: 0b00, Length: 2}
+	expected_codes['C'] = Code{Value: 0b01, Length: 2}
+	expected_codes['E'] = Code{Value: 0b10, Length: 2}
+	expected_codes['B'] = Code{Value: 0b110, Length: 3}
+	expected_codes['D'] = Code{Value: 0b111, Length: 3}
+
+	if codes != expected_codes {
+		t.Fatalf(`Test New Code Table Failed.
+		codes: %v,
+		expected: %v`, codes, expected_codes)
+	}
+}
+
+func TestCodeLengthsRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		tree        *Node
+	}
+
+	test_cases := []test_case{
+		{
+			description: "mock tree",
+			tree:        MOCK_TREE,
+		},
+		{
+			description: "mock tree 2",
+			tree:        MOCK_TREE_2,
+		},
+		{
+			description: "single-noded tree",
+			tree:        &Node{ch: 'A', weight: 3},
+		},
+		{
+			description: "all bytes",
+			tree:        BuildTree(randomData(10_000, 256, 4)),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			lengths := CodeLengths(scenario.tree)
+
+			var buf bytes.Buffer
+			w := NewBitWriter(&buf)
+			size := w.WriteCodeLengths(lengths)
+			w.Flush()
+			r := NewBitReader(buf.Bytes())
+			read_lengths, err := r.ReadCodeLengths()
+
+			tree, build_err := treeFromCodeLengths(read_lengths)
+			if err != nil || build_err != nil ||
+				read_lengths != lengths ||
+				r.position() != int(size) ||
+				NewCodeTable(tree) != NewCodeTable(scenario.tree) {
+				t.Fatalf(`Test %d Failed.
+				Got: %v, %v, %v, size read: %d,
+				Wanted: %v, <nil>, <nil>, size read: %d`, scenarioIdx, read_lengths, err, build_err, r.position(), lengths, size)
+			}
+		})
+	}
+}
+
+func TestCheckCodeLengths_ShouldFail(t *testing.T) {
+	type test_case struct {
+		description string
+		lengths     map[byte]uint8
+	}
+
+	test_cases := []test_case{
+		{
+			description: "no codes",
+			lengths:     map[byte]uint8{},
+		},
+		{
+			description: "oversubscribed",
+			lengths:     map[byte]uint8{'A': 1, 'B': 1, 'C': 2},
+		},
+		{
+			description: "code too long",
+			lengths:     map[byte]uint8{'A': 1, 'B': 64},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var lengths [256]uint8
+			for b, length := range scenario.lengths {
+				lengths[b] = length
+			}
+
+			if err := checkCodeLengths(lengths[:]); err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
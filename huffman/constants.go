@@ -0,0 +1,3 @@
+package huffman
+
+const __DEBUG__ = false
@@ -1,6 +1,4 @@
-package main
-
-const __DEBUG__ = false
+package huffman
 
 /*
 				20
@@ -0,0 +1,217 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// contextTables holds the code tables of an order-1 block: a table per
+// preceding byte, contexts too rare to pay for their own table sharing one.
+type contextTables struct {
+	shared *CodeTable
+	own    [256]*CodeTable
+}
+
+// table returns the code table of the byte following prev.
+func (c *contextTables) table(prev byte) *CodeTable {
+	if c.own[prev] != nil {
+		return c.own[prev]
+	}
+	return c.shared
+}
+
+// encodeContextBlock writes data as a single order-1 block, each byte coded
+// with the table of the byte before it, without its size prefix. The first
+// byte of the block follows a 0 byte.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeContextBlock(w io.Writer, data []byte) (int, error) {
+	freqs := make([][256]int, 256)
+	var prev byte
+	for _, b := range data {
+		freqs[prev][b]++
+		prev = b
+	}
+
+	// a context gets its own table when it saves more than the table costs,
+	// compared to coding the context with the order-0 codes of the block
+	order0 := e.codeLengths(byteFrequencies(data))
+	var tables contextTables
+	var own_lengths [256][256]uint8
+	var shared_freqs [256]int
+	shared := false
+	for context := range freqs {
+		lengths := e.codeLengths(freqs[context])
+		if longestCode(lengths) == 0 {
+			continue
+		}
+		if encodedSize(freqs[context], lengths)+codeLengthsSize(lengths) < encodedSize(freqs[context], order0) {
+			own_lengths[context] = lengths
+			codes := NewCanonicalCodeTable(lengths)
+			tables.own[context] = &codes
+			continue
+		}
+		for b, freq := range freqs[context] {
+			shared_freqs[b] += freq
+		}
+		shared = true
+	}
+
+	bw := BitWriter{io_writer: w}
+	for _, b := range binary.AppendUvarint(nil, uint64(len(data))) {
+		bw.WriteByte(b)
+	}
+	if shared {
+		shared_lengths := e.codeLengths(shared_freqs)
+		codes := NewCanonicalCodeTable(shared_lengths)
+		tables.shared = &codes
+		bw.WriteBit(1)
+		bw.WriteCodeLengths(shared_lengths)
+	} else {
+		bw.WriteBit(0)
+	}
+	for context := range tables.own {
+		if tables.own[context] == nil {
+			bw.WriteBit(0)
+			continue
+		}
+		bw.WriteBit(1)
+		bw.WriteCodeLengths(own_lengths[context])
+	}
+
+	prev = 0
+	for _, b := range data {
+		bw.WriteCode(tables.table(prev)[b])
+		prev = b
+	}
+	e.tree = nil
+	e.codes = CodeTable{}
+
+	return bw.FlushPadded()
+}
+
+// codeLengths returns the code lengths of the Huffman tree of bytes occuring
+// freqs times, limited to MaxCodeLength bits.
+func (e *Encoder) codeLengths(freqs [256]int) [256]uint8 {
+	lengths := CodeLengths(treeFromFrequencies(freqs))
+	if e.MaxCodeLength != 0 && longestCode(lengths) > e.MaxCodeLength {
+		// MaxCodeLength is at least MinCodeLength, enough for any bytes
+		lengths, _ = LimitedCodeLengths(freqs, e.MaxCodeLength)
+	}
+	return lengths
+}
+
+// encodedSize returns the number of bits of bytes occuring freqs times once
+// coded with codes of lengths.
+func encodedSize(freqs [256]int, lengths [256]uint8) int {
+	size := 0
+	for b, freq := range freqs {
+		size += freq * int(lengths[b])
+	}
+	return size
+}
+
+// codeLengthsSize returns the number of bits WriteCodeLengths takes for lengths.
+func codeLengthsSize(lengths [256]uint8) int {
+	size := 0
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			size += 7
+			i++
+			continue
+		}
+		for i < len(lengths) && lengths[i] == 0 {
+			i++
+		}
+		size += 9
+	}
+	return size
+}
+
+// contextBlock decodes an order-1 block.
+type contextBlock struct {
+	contexts [256]*decodeTable // decode table of each context
+	prev     byte              // context of the next symbol
+	count    uint64            // number of symbols left in the block
+}
+
+// readContextTables reads the code tables of an order-1 block of count
+// symbols.
+func (z *Reader) readContextTables(count uint64) (*contextBlock, error) {
+	d := &contextBlock{count: count}
+
+	has_shared, err := z.br.ReadBit()
+	if err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	var shared *decodeTable
+	if has_shared == 1 {
+		if shared, err = z.readDecodeTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	for context := range d.contexts {
+		has_own, err := z.br.ReadBit()
+		if err != nil {
+			return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+		}
+		d.contexts[context] = shared
+		if has_own == 1 {
+			if d.contexts[context], err = z.readDecodeTable(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// decode returns the next byte of the block, coded with the table of the
+// byte before it. Returns io.EOF at the end of the block.
+func (d *contextBlock) decode(br *BitReader) (byte, error) {
+	if d.count == 0 {
+		return 0, io.EOF
+	}
+	table := d.contexts[d.prev]
+	if table == nil {
+		return 0, fmt.Errorf("huffman: no code table for context %d", d.prev)
+	}
+	b, err := table.decode(br)
+	if err != nil {
+		return 0, symbolError(err, d.count)
+	}
+	d.count--
+	d.prev = b
+	return b, nil
+}
+
+// readDecodeTable reads a code lengths table and builds its decode table.
+func (z *Reader) readDecodeTable() (*decodeTable, error) {
+	lengths, err := z.br.ReadCodeLengths()
+	if err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	root, err := treeFromCodeLengths(lengths)
+	if err != nil {
+		return nil, err
+	}
+	return newDecodeTable(root), nil
+}
+
+// symbolError returns the error of decoding a symbol of a block with count
+// symbols left, io.EOF meaning the block ends early.
+func symbolError(err error, count uint64) error {
+	if err == io.EOF {
+		return fmt.Errorf("huffman: block ends %d symbols early %w", count, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// unexpected turns io.EOF into io.ErrUnexpectedEOF, for reads in the middle
+// of a block.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+// markovData returns data where each byte mostly depends on the byte before
+// it: every byte is followed by one of a few bytes of its own.
+func markovData(size int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	var successors [256][4]byte
+	for i := range successors {
+		for j := range successors[i] {
+			successors[i][j] = byte(rng.Intn(64))
+		}
+	}
+
+	data := make([]byte, size)
+	var prev byte
+	for i := range data {
+		data[i] = successors[prev][rng.Intn(4)*rng.Intn(4)/3]
+		prev = data[i]
+	}
+	return data
+}
+
+// sourceText returns a text corpus: a snapshot of the Go sources of the
+// package, fixed so that the sizes the tests measure don't change with them.
+func sourceText() []byte {
+	text, err := os.ReadFile(filepath.Join("testdata", "source.txt"))
+	if err != nil {
+		panic(err)
+	}
+	return text
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single repeating character",
+			data:        []byte("aaaaaaa"),
+		},
+		{
+			description: "text",
+			data:        []byte("huffman coding and decoding in golang"),
+		},
+		{
+			description: "source text",
+			data:        sourceText(),
+		},
+		{
+			description: "binary data",
+			data:        randomData(200_000, 256, 9),
+		},
+		{
+			description: "several blocks",
+			data:        markovData(3*MinBlockSize+17, 10),
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := Encoder{BlockSize: scenario.block_size, Order1: true}
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Flags&FlagOrder1 == 0 {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, header %+v,
+				Wanted: %d bytes, <nil>, order-1 header`, scenarioIdx, len(decoded), err, zr.Header(), len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestContextGain(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "source text",
+			data:        sourceText(),
+		},
+		{
+			description: "markov data",
+			data:        markovData(1<<20, 11),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			order0, _ := (&Encoder{}).Encode(io.Discard, scenario.data)
+			order1, _ := (&Encoder{Order1: true}).Encode(io.Discard, scenario.data)
+			t.Logf("%d bytes: order-0 %d bytes, order-1 %d bytes (%.02f%%)", len(scenario.data), order0, order1, 100*float64(order1)/float64(order0))
+
+			if order1 >= order0 {
+				t.Fatalf(`Test %d Failed.
+				Got: order-1 %d bytes,
+				Wanted: less than order-0 %d bytes`, scenarioIdx, order1, order0)
+			}
+		})
+	}
+}
+
+func TestCodeLengthsSize(t *testing.T) {
+	for _, data := range [][]byte{[]byte("a"), []byte("huffman coding"), randomData(10_000, 256, 12)} {
+		lengths := CodeLengths(BuildTree(data))
+		var w BitWriter
+		if size := w.WriteCodeLengths(lengths); codeLengthsSize(lengths) != int(size) {
+			t.Fatalf(`Test Code Lengths Size Failed.
+			Got: %d,
+			Wanted: %d`, codeLengthsSize(lengths), size)
+		}
+	}
+}
+
+func TestOrder1Adaptive_ShouldFail(t *testing.T) {
+	e := Encoder{Adaptive: true, Order1: true}
+	if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+		t.Fatalf("Encode with adaptive and order-1 modes: got err <nil>, wanted err != <nil>")
+	}
+}
+
+// BenchmarkEncodeOrder1 reports the size of the order-1 encoding relative to
+// the order-0 one.
+func BenchmarkEncodeOrder1(b *testing.B) {
+	corpora := append(benchmarkCorpora(),
+		benchmarkCorpus{"source", sourceText()},
+		benchmarkCorpus{"markov", markovData(4<<20, 13)},
+	)
+	for _, corpus := range corpora {
+		b.Run(corpus.name, func(b *testing.B) {
+			order0, _ := (&Encoder{}).Encode(io.Discard, corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			order1 := 0
+			for i := 0; i < b.N; i++ {
+				order1, _ = (&Encoder{Order1: true}).Encode(io.Discard, corpus.data)
+			}
+			b.ReportMetric(float64(order1)/float64(order0), "size/order0")
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"math/bits"
+)
+
+// Format is the format of the streams written by an Encoder.
+type Format uint8
+
+const (
+	// FormatHuffman is the format of this package, read by Reader.
+	FormatHuffman Format = iota
+
+	// FormatDeflate is a raw DEFLATE stream (RFC 1951), read by compress/flate.
+	FormatDeflate
+
+	// FormatGzip is a DEFLATE stream in gzip framing (RFC 1952), read by
+	// compress/gzip and any gzip implementation.
+	FormatGzip
+
+	// FormatZlib is a DEFLATE stream in zlib framing (RFC 1950), read by
+	// compress/zlib.
+	FormatZlib
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatHuffman:
+		return "huff"
+	case FormatDeflate:
+		return "deflate"
+	case FormatGzip:
+		return "gzip"
+	case FormatZlib:
+		return "zlib"
+	}
+	return fmt.Sprintf("Format(%d)", uint8(f))
+}
+
+const (
+	// DEFLATE block types
+	blockStored  uint64 = 0
+	blockFixed   uint64 = 1
+	blockDynamic uint64 = 2
+
+	endOfBlock int = 256
+
+	// number of symbols of the alphabet the code lengths of a dynamic block
+	// are coded with, and longest code of this alphabet
+	codeLengthSymbols int = 19
+	codeLengthLimit   int = 7
+)
+
+// order code length code lengths are stored in
+var codeLengthOrder = [codeLengthSymbols]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// lsbWriter packs bits least-significant first, the bit order of DEFLATE.
+type lsbWriter struct {
+	w     io.Writer
+	buf   []byte
+	acc   uint64
+	nbits uint8
+	err   error
+}
+
+// writeBits appends the n lowest bits of value, least significant first,
+// n being at most 32.
+func (w *lsbWriter) writeBits(value uint64, n uint8) {
+	w.acc |= (value & (1<<n - 1)) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+	if len(w.buf) >= writeChunkSize {
+		w.flush()
+	}
+}
+
+// writeCode appends a Huffman code, whose first bit is its most significant.
+func (w *lsbWriter) writeCode(c Code) {
+	w.writeBits(uint64(bits.Reverse32(uint32(c.Value))>>(32-c.Length)), c.Length)
+}
+
+// align pads the last byte with zeros.
+func (w *lsbWriter) align() {
+	if w.nbits > 0 {
+		w.writeBits(0, 8-w.nbits)
+	}
+}
+
+// flush writes the whole bytes to the underlying io.Writer.
+func (w *lsbWriter) flush() error {
+	if w.err == nil && len(w.buf) > 0 {
+		_, w.err = w.w.Write(w.buf)
+	}
+	w.buf = w.buf[:0]
+	return w.err
+}
+
+// deflateWriter writes the DEFLATE encoding of its input for Writer, a
+// dynamic Huffman block for each block of input.
+type deflateWriter struct {
+	enc    *Encoder
+	bw     lsbWriter
+	data   []byte // input of the current block
+	header bool   // the header of the framing has been written
+	length uint32 // length of the input, modulo 2^32
+	digest hash.Hash32
+}
+
+func newDeflateWriter(w io.Writer, e *Encoder) *deflateWriter {
+	d := &deflateWriter{enc: e, bw: lsbWriter{w: w}}
+	switch e.Format {
+	case FormatGzip:
+		d.digest = crc32.NewIEEE()
+	case FormatZlib:
+		d.digest = adler32.New()
+	}
+	return d
+}
+
+func (d *deflateWriter) Write(p []byte) (int, error) {
+	d.writeHeader()
+	d.length += uint32(len(p))
+	if d.digest != nil {
+		d.digest.Write(p)
+	}
+
+	block_size := d.enc.blockSize()
+	n := 0
+	for len(p) > 0 {
+		size := min(block_size-len(d.data), len(p))
+		d.data = append(d.data, p[:size]...)
+		p = p[size:]
+		n += size
+
+		if len(d.data) == block_size {
+			d.writeBlock(false)
+		}
+	}
+	return n, d.bw.err
+}
+
+// Flush writes the pending input as a block, followed by an empty stored
+// block so that the output ends on a byte boundary, like zlib's sync flush.
+func (d *deflateWriter) Flush() error {
+	d.writeHeader()
+	if len(d.data) > 0 {
+		d.writeBlock(false)
+	}
+	d.bw.writeBits(blockStored<<1, 3)
+	d.bw.align()
+	d.bw.writeBits(0xffff_0000, 32)
+	return d.bw.flush()
+}
+
+// Close writes the pending input as the final block, and the trailer of
+// the framing.
+func (d *deflateWriter) Close() error {
+	d.writeHeader()
+	if len(d.data) > 0 {
+		d.writeBlock(true)
+	} else {
+		// an empty final block with the fixed codes, whose end of block
+		// code is 7 zero bits
+		d.bw.writeBits(blockFixed<<1|1, 3)
+		d.bw.writeBits(0, 7)
+	}
+	d.bw.align()
+
+	switch d.enc.Format {
+	case FormatGzip:
+		d.bw.buf = binary.LittleEndian.AppendUint32(d.bw.buf, d.digest.Sum32())
+		d.bw.buf = binary.LittleEndian.AppendUint32(d.bw.buf, d.length)
+	case FormatZlib:
+		d.bw.buf = binary.BigEndian.AppendUint32(d.bw.buf, d.digest.Sum32())
+	}
+	return d.bw.flush()
+}
+
+// writeHeader writes the header of the framing, before anything else.
+func (d *deflateWriter) writeHeader() {
+	if d.header {
+		return
+	}
+	d.header = true
+	switch d.enc.Format {
+	case FormatGzip:
+		// magic, deflate method, no flags, no modification time, no extra
+		// flags, unknown OS
+		d.bw.buf = append(d.bw.buf, 0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255)
+	case FormatZlib:
+		// deflate with a 32K window, and the check bits making the 2 bytes
+		// a multiple of 31
+		cmf := uint16(0x78)
+		flg := uint16(2 << 6)
+		flg += 31 - (cmf<<8|flg)%31
+		d.bw.buf = append(d.bw.buf, byte(cmf), byte(flg))
+	}
+}
+
+// writeBlock writes the pending input as a dynamic Huffman block.
+func (d *deflateWriter) writeBlock(final bool) {
+	var tokens []token
+	if d.enc.Level > 0 {
+		tokens = newMatchFinder(d.data, d.enc.window(), d.enc.Level).tokens()
+	} else {
+		tokens = make([]token, len(d.data))
+		for i, b := range d.data {
+			tokens[i] = token{distance: uint16(b)}
+		}
+	}
+	d.data = d.data[:0]
+
+	literal_freqs := make([]int, literalLengthSymbols)
+	distance_freqs := make([]int, distanceSymbols)
+	for _, t := range tokens {
+		if t.length == 0 {
+			literal_freqs[t.distance]++
+			continue
+		}
+		literal_freqs[257+int(lengthCodes[t.length])]++
+		distance_freqs[distanceCode(int(t.distance))]++
+	}
+	literal_freqs[endOfBlock] = 1
+	literal_lengths := deflateLengths(literal_freqs, lzCodeLimit)
+	distance_lengths := deflateLengths(distance_freqs, lzCodeLimit)
+
+	var header uint64 = blockDynamic << 1
+	if final {
+		header |= 1
+	}
+	d.bw.writeBits(header, 3)
+	d.writeCodeLengths(literal_lengths, distance_lengths)
+
+	literal_codes := canonicalCodes(literal_lengths)
+	distance_codes := canonicalCodes(distance_lengths)
+	for _, t := range tokens {
+		if t.length == 0 {
+			d.bw.writeCode(literal_codes[t.distance])
+			continue
+		}
+		code := lengthCodes[t.length]
+		d.bw.writeCode(literal_codes[257+int(code)])
+		d.bw.writeBits(uint64(int(t.length)-lengthBase[code]), lengthExtra[code])
+
+		distance := int(t.distance)
+		code = uint8(distanceCode(distance))
+		d.bw.writeCode(distance_codes[code])
+		d.bw.writeBits(uint64(distance-distanceBase[code]), distanceExtra[code])
+	}
+	d.bw.writeCode(literal_codes[endOfBlock])
+}
+
+// deflateLengths returns length-limited code lengths for freqs, making sure
+// at least 2 symbols get a code like zlib does, as some decoders reject a
+// table with a single code.
+func deflateLengths(freqs []int, limit int) []uint8 {
+	used := 0
+	for _, freq := range freqs {
+		if freq > 0 {
+			used++
+		}
+	}
+	for symbol := 0; used < 2; symbol++ {
+		if freqs[symbol] == 0 {
+			freqs[symbol] = 1
+			used++
+		}
+	}
+	return packageMerge(freqs, limit)
+}
+
+// writeCodeLengths writes the code lengths of a dynamic block: the lengths
+// of both tables, trailing zeros dropped, as one sequence coded with a third
+// table in which runs are coded as repeats.
+func (d *deflateWriter) writeCodeLengths(literal_lengths, distance_lengths []uint8) {
+	hlit := len(literal_lengths)
+	for hlit > 257 && literal_lengths[hlit-1] == 0 {
+		hlit--
+	}
+	hdist := len(distance_lengths)
+	for hdist > 1 && distance_lengths[hdist-1] == 0 {
+		hdist--
+	}
+	lengths := append(append([]uint8{}, literal_lengths[:hlit]...), distance_lengths[:hdist]...)
+
+	// code length symbols, each with its extra bits: 16 repeats the previous
+	// length 3 to 6 times, 17 and 18 repeat a zero 3 to 10 and 11 to 138 times
+	type repeat struct {
+		symbol uint8
+		extra  uint8
+	}
+	var symbols []repeat
+	for i := 0; i < len(lengths); {
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == lengths[i] {
+			run++
+		}
+		i += run
+
+		length := lengths[i-run]
+		if length == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				symbols = append(symbols, repeat{18, uint8(n - 11)})
+				run -= n
+			}
+			if run >= 3 {
+				symbols = append(symbols, repeat{17, uint8(run - 3)})
+				run = 0
+			}
+		} else {
+			symbols = append(symbols, repeat{length, 0})
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				symbols = append(symbols, repeat{16, uint8(n - 3)})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			symbols = append(symbols, repeat{length, 0})
+		}
+	}
+
+	freqs := make([]int, codeLengthSymbols)
+	for _, s := range symbols {
+		freqs[s.symbol]++
+	}
+	code_lengths := deflateLengths(freqs, codeLengthLimit)
+	codes := canonicalCodes(code_lengths)
+
+	hclen := codeLengthSymbols
+	for hclen > 4 && code_lengths[codeLengthOrder[hclen-1]] == 0 {
+		hclen--
+	}
+	d.bw.writeBits(uint64(hlit-257), 5)
+	d.bw.writeBits(uint64(hdist-1), 5)
+	d.bw.writeBits(uint64(hclen-4), 4)
+	for _, symbol := range codeLengthOrder[:hclen] {
+		d.bw.writeBits(uint64(code_lengths[symbol]), 3)
+	}
+
+	extra_bits := [codeLengthSymbols]uint8{16: 2, 17: 3, 18: 7}
+	for _, s := range symbols {
+		d.bw.writeCode(codes[s.symbol])
+		d.bw.writeBits(uint64(s.extra), extra_bits[s.symbol])
+	}
+}
@@ -0,0 +1,152 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+)
+
+// inflate decodes a stream of format with the standard library.
+func inflate(format Format, encoded []byte) ([]byte, error) {
+	var r io.Reader
+	switch format {
+	case FormatDeflate:
+		r = flate.NewReader(bytes.NewReader(encoded))
+	case FormatGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(encoded))
+		if err != nil {
+			return nil, err
+		}
+		zr.Multistream(false)
+		r = zr
+	case FormatZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(encoded))
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	return io.ReadAll(r)
+}
+
+func TestDeflateRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "empty",
+			data:        []byte{},
+		},
+		{
+			description: "single byte",
+			data:        []byte("a"),
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 100_000),
+		},
+		{
+			description: "all bytes",
+			data:        randomData(100_000, 256, 21),
+		},
+		{
+			description: "log lines",
+			data:        logData(300_000, 22),
+		},
+		{
+			description: "several blocks",
+			data:        logData(3*MinBlockSize+17, 23),
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, format := range []Format{FormatDeflate, FormatGzip, FormatZlib} {
+				for _, level := range []int{0, 1, 6, MaxLevel} {
+					var encoded bytes.Buffer
+					e := Encoder{BlockSize: scenario.block_size, Level: level, Format: format}
+					if _, err := e.Encode(&encoded, scenario.data); err != nil {
+						t.Fatalf("Test %d Failed. format %d level %d encode error: %v", scenarioIdx, format, level, err)
+					}
+
+					decoded, err := inflate(format, encoded.Bytes())
+
+					if err != nil || !bytes.Equal(decoded, scenario.data) {
+						t.Fatalf(`Test %d Failed. format %d level %d
+						Got: %d bytes, %v,
+						Wanted: %d bytes, <nil>`, scenarioIdx, format, level, len(decoded), err, len(scenario.data))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDeflateWriterFlush(t *testing.T) {
+	data := logData(10_000, 24)
+	var encoded bytes.Buffer
+	zw := (&Encoder{Level: 6, Format: FormatDeflate}).NewWriter(&encoded)
+	zw.Write(data[:5000])
+	if err := zw.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	// everything written before the flush decodes from the output so far
+	flushed := make([]byte, 5000)
+	_, err := io.ReadFull(flate.NewReader(bytes.NewReader(encoded.Bytes())), flushed)
+	if err != nil || !bytes.Equal(flushed, data[:5000]) {
+		t.Fatalf(`Test Flush Failed.
+		Got: %v,
+		Wanted: the first 5000 bytes, <nil>`, err)
+	}
+
+	zw.Write(data[5000:])
+	zw.Close()
+	decoded, err := inflate(FormatDeflate, encoded.Bytes())
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf(`Test Close Failed.
+		Got: %d bytes, %v,
+		Wanted: %d bytes, <nil>`, len(decoded), err, len(data))
+	}
+}
+
+func TestDeflateSize(t *testing.T) {
+	data := logData(1<<20, 25)
+	for _, level := range []int{1, 6, MaxLevel} {
+		ours, _ := (&Encoder{Level: level, Format: FormatDeflate}).Encode(io.Discard, data)
+
+		var theirs bytes.Buffer
+		fw, _ := flate.NewWriter(&theirs, level)
+		fw.Write(data)
+		fw.Close()
+		t.Logf("level %d: %d bytes, compress/flate %d bytes (%.02f%%)", level, ours, theirs.Len(), 100*float64(ours)/float64(theirs.Len()))
+
+		// blocks are coded independently, so allow some slack
+		if ours > theirs.Len()*11/10 {
+			t.Fatalf(`Test level %d Failed.
+			Got: %d bytes,
+			Wanted: within 10%% of compress/flate's %d bytes`, level, ours, theirs.Len())
+		}
+	}
+}
+
+func TestDeflate_ShouldFail(t *testing.T) {
+	for _, e := range []Encoder{
+		{Format: FormatZlib + 1},
+		{Format: FormatGzip, Adaptive: true},
+		{Format: FormatDeflate, Order1: true},
+		{Format: FormatZlib, Tables: 2},
+	} {
+		if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with %+v: got err <nil>, wanted err != <nil>", e)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFile writes the output of write to a temporary file next to name,
+// moved to name once write and the file's Sync and Close succeed, so that
+// name is either left as it was or holds the whole output. The temporary
+// file is removed on failure. Unless overwrite is set, an existing name is
+// an error wrapping fs.ErrExist, even if it's created while write runs: the
+// file is then linked to name, which fails if name exists, rather than
+// renamed over it. The output gets the permissions and modification time of
+// input.
+func writeFile(name string, overwrite bool, input fs.FileInfo, write func(w io.Writer) error) (err error) {
+	if !overwrite {
+		if _, stat_err := os.Lstat(name); stat_err == nil {
+			return fmt.Errorf("huffman: %s: %w", name, fs.ErrExist)
+		}
+	}
+
+	f, create_err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if create_err != nil {
+		return create_err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(input.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chtimes(f.Name(), input.ModTime(), input.ModTime()); err != nil {
+		return err
+	}
+	if overwrite {
+		return os.Rename(f.Name(), name)
+	}
+	if err = os.Link(f.Name(), name); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			err = fmt.Errorf("huffman: %s: %w", name, fs.ErrExist)
+		}
+		return err
+	}
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,161 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeFileOutput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	data := sourceText()
+	os.WriteFile(input, data, 0640)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(input, mtime, mtime)
+
+	output := filepath.Join(dir, "input.huff")
+	// larger than the encoding, none of it may be left
+	stale := bytes.Repeat([]byte{0xff}, 2*len(data))
+	os.WriteFile(output, stale, 0600)
+
+	if _, err := (&Encoder{}).EncodeFile(input, output); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf(`Test Existing Output Failed.
+		Got: %v,
+		Wanted: %v`, err, fs.ErrExist)
+	}
+	if kept, _ := os.ReadFile(output); !bytes.Equal(kept, stale) {
+		t.Fatalf("Test Existing Output Failed. the output was changed")
+	}
+
+	if _, err := (&Encoder{Overwrite: true}).EncodeFile(input, output); err != nil {
+		t.Fatalf("Test Overwrite Failed. encode error: %v", err)
+	}
+	info, _ := os.Stat(output)
+	if info.Mode().Perm() != 0640 || !info.ModTime().Equal(mtime) {
+		t.Fatalf(`Test Attributes Failed.
+		Got: %v, %v,
+		Wanted: %v, %v`, info.Mode().Perm(), info.ModTime(), fs.FileMode(0640), mtime)
+	}
+
+	decoded := filepath.Join(dir, "decoded")
+	if _, err := (&Decoder{}).DecodeFile(output, decoded); err != nil {
+		t.Fatalf("Test Overwrite Failed. decode error: %v", err)
+	}
+	if got, _ := os.ReadFile(decoded); !bytes.Equal(got, data) {
+		t.Fatalf(`Test Overwrite Failed.
+		Got: %d bytes,
+		Wanted: %d bytes`, len(got), len(data))
+	}
+}
+
+func TestWriteFileRace(t *testing.T) {
+	dir := t.TempDir()
+	input, _ := os.Stat(dir)
+
+	type test_case struct {
+		description string
+		overwrite   bool
+		expected    string
+	}
+
+	test_cases := []test_case{
+		{
+			description: "kept",
+			expected:    "raced",
+		},
+		{
+			description: "overwritten",
+			overwrite:   true,
+			expected:    "written",
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			output := filepath.Join(dir, "output")
+			os.Remove(output)
+			err := writeFile(output, scenario.overwrite, input, func(w io.Writer) error {
+				// the output appears after it was checked for
+				os.WriteFile(output, []byte("raced"), 0600)
+				_, err := w.Write([]byte("written"))
+				return err
+			})
+
+			if !scenario.overwrite && !errors.Is(err, fs.ErrExist) || scenario.overwrite && err != nil {
+				t.Fatalf("Test %d Failed. unexpected error: %v", scenarioIdx, err)
+			}
+			if got, _ := os.ReadFile(output); string(got) != scenario.expected {
+				t.Fatalf(`Test %d Failed.
+				Got: %q,
+				Wanted: %q`, scenarioIdx, got, scenario.expected)
+			}
+			// no temporary file is left behind
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Fatalf("Test %d Failed. %d files left in the directory", scenarioIdx, len(entries))
+			}
+		})
+	}
+}
+
+func TestDecodeFile_ShouldFail(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.huff")
+	var encoded bytes.Buffer
+	(&Encoder{}).Encode(&encoded, sourceText())
+	// a checksum error, once the whole output is written
+	tampered := encoded.Bytes()
+	tampered[len(tampered)-1] ^= 1
+	os.WriteFile(input, tampered, 0600)
+
+	type test_case struct {
+		description string
+		existing    []byte
+		files       int // files in the directory afterwards
+	}
+
+	test_cases := []test_case{
+		{
+			description: "new output",
+			files:       1,
+		},
+		{
+			description: "existing output",
+			existing:    []byte("kept"),
+			files:       2,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			output := filepath.Join(dir, "output")
+			os.Remove(output)
+			if scenario.existing != nil {
+				os.WriteFile(output, scenario.existing, 0600)
+			}
+
+			if _, err := (&Decoder{Overwrite: true}).DecodeFile(input, output); err == nil {
+				t.Fatalf("Test %d Failed. got err <nil>, wanted err != <nil>", scenarioIdx)
+			}
+
+			got, read_err := os.ReadFile(output)
+			if scenario.existing == nil && !errors.Is(read_err, fs.ErrNotExist) {
+				t.Fatalf("Test %d Failed. got an output of %d bytes, wanted none", scenarioIdx, len(got))
+			}
+			if scenario.existing != nil && !bytes.Equal(got, scenario.existing) {
+				t.Fatalf(`Test %d Failed.
+				Got: %q,
+				Wanted: %q`, scenarioIdx, got, scenario.existing)
+			}
+			// no temporary file is left behind
+			if entries, _ := os.ReadDir(dir); len(entries) != scenario.files {
+				t.Fatalf("Test %d Failed. %d files left in the directory", scenarioIdx, len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Magic starts every stream written since format version 1.
+var Magic = [4]byte{'H', 'U', 'F', 'F'}
+
+// FormatVersion is the version of the format written by Writer.
+// Version 2 records the number of symbols of each block, version 1 blocks
+// end with a trailing cursor byte instead.
+const FormatVersion uint8 = 2
+
+// LegacyVersion is the version reported for streams without a header,
+// written before format version 1 as a single tree and bitstream.
+const LegacyVersion uint8 = 0
+
+// size of the header: magic, version and flags
+const headerSize int = 7
+
+// largest tree of a legacy stream: 256 leaves of 9 bits and 255 internal nodes
+const maxLegacyTreeSize uint32 = 256*9 + 255
+
+var (
+	// ErrFormat is returned for input that isn't a Huffman-encoded stream.
+	ErrFormat = errors.New("huffman: not a huffman-encoded stream")
+
+	// ErrVersion is returned for streams of a newer format version, or
+	// using flags unknown to this version.
+	ErrVersion = errors.New("huffman: unsupported format version")
+)
+
+// Flags describe how a stream is encoded.
+type Flags uint16
+
+const (
+	// FlagChecksum marks streams ending with a trailer holding the length
+	// and the CRC-32 of the original data, checked when decoding.
+	FlagChecksum Flags = 1 << iota
+
+	// FlagAdaptive marks streams coded in a single pass with an adaptive
+	// Huffman tree: instead of blocks, the header is followed by a single
+	// bitstream ending with an end of stream symbol, padded to a whole byte.
+	FlagAdaptive
+
+	// FlagOrder1 marks streams whose bytes are coded with a table chosen by
+	// the byte before them. Blocks start with a table shared by the rare
+	// contexts, if any, then give for each of the 256 contexts a bit telling
+	// whether its own table follows.
+	FlagOrder1
+
+	// FlagMultiTable marks streams whose blocks are coded with several
+	// tables. Blocks give the number of tables on 3 bits, the tables, then
+	// the move-to-front coded selector of each segment of 50 bytes.
+	FlagMultiTable
+
+	// FlagLZ77 marks streams whose blocks are split into literals and
+	// matches of earlier bytes of the block, coded with a literal/length
+	// table of 286 symbols and a distance table of 30 symbols, DEFLATE's
+	// alphabets, the symbol count of a block counting literals and matches.
+	FlagLZ77
+
+	// FlagTransforms marks streams whose blocks are transformed before
+	// they're coded. The header is followed by the number of transforms
+	// and each of them, a byte each, in the order they're applied. The
+	// symbol count of a block counts transformed bytes.
+	FlagTransforms
+
+	// FlagCoder marks streams whose blocks are coded with an entropy coder
+	// other than Huffman codes. The header is followed by a byte giving the
+	// Coder, before the transforms. The symbol count of a block is followed
+	// by the encoding of its EntropyCoder.
+	FlagCoder
+
+	// FlagSymbols marks streams whose blocks are coded as symbols of more
+	// than a byte. The header is followed by a byte giving the Alphabet,
+	// after the coder and before the transforms. The symbol count of a block
+	// counts these symbols and is followed by their dictionary.
+	FlagSymbols
+
+	// FlagInterleaved marks streams whose blocks are coded as 4 bitstreams,
+	// each coding a quarter of the block, which can be decoded side by side.
+	// The code lengths table is followed by a jump table giving the size of
+	// the first 3 streams.
+	FlagInterleaved
+)
+
+// names of the flags, lowest bit first
+var flagNames = []string{"checksum", "adaptive", "order1", "multitable", "lz77", "transforms", "coder", "symbols", "interleaved"}
+
+// String returns the names of the flags set in f separated by "|", unknown
+// flags as a hex number.
+func (f Flags) String() string {
+	var names []string
+	for i, name := range flagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if unknown := f &^ knownFlags; unknown != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint16(unknown)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// flags known to this version
+const knownFlags Flags = FlagChecksum | FlagAdaptive | FlagOrder1 | FlagMultiTable | FlagLZ77 | FlagTransforms | FlagCoder | FlagSymbols | FlagInterleaved
+
+// size of the trailer: 8-byte length and 4-byte CRC-32
+const trailerSize int = 12
+
+// CorruptionError is returned when the decoded data doesn't match the
+// length or checksum recorded by the encoder.
+type CorruptionError struct {
+	Field    string // "length" or "checksum"
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *CorruptionError) Error() string {
+	if e.Field == "checksum" {
+		return fmt.Sprintf("huffman: corrupt stream, checksum mismatch: expected %08x, got %08x", e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("huffman: corrupt stream, %s mismatch: expected %d, got %d", e.Field, e.Expected, e.Actual)
+}
+
+// Header is the header of an encoded stream.
+type Header struct {
+	Version uint8
+	Flags   Flags
+}
+
+// writeHeader writes the magic, version and flags of a stream.
+func writeHeader(w io.Writer, h Header) error {
+	header := make([]byte, 0, headerSize)
+	header = append(header, Magic[:]...)
+	header = append(header, h.Version)
+	header = binary.BigEndian.AppendUint16(header, uint16(h.Flags))
+	_, err := w.Write(header)
+	return err
+}
+
+// readHeader reads the header of a stream. A stream not starting with Magic
+// is a legacy stream: its first 4 bytes are the size of its tree, returned
+// as the prefix already read.
+func readHeader(r io.Reader) (Header, []byte, error) {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return Header{}, nil, fmt.Errorf("%w: stream shorter than its header", ErrFormat)
+		}
+		return Header{}, nil, err
+	}
+
+	if !bytes.Equal(prefix, Magic[:]) {
+		tree_size := bytesToInt(prefix)
+		if tree_size == 0 || tree_size > maxLegacyTreeSize {
+			return Header{}, nil, ErrFormat
+		}
+		return Header{Version: LegacyVersion}, prefix, nil
+	}
+
+	rest := make([]byte, headerSize-len(Magic))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return Header{}, nil, fmt.Errorf("%w: stream shorter than its header", ErrFormat)
+		}
+		return Header{}, nil, err
+	}
+
+	h := Header{
+		Version: rest[0],
+		Flags:   Flags(binary.BigEndian.Uint16(rest[1:])),
+	}
+	if h.Version == LegacyVersion || h.Version > FormatVersion {
+		return h, nil, fmt.Errorf("%w %d, this version reads up to %d", ErrVersion, h.Version, FormatVersion)
+	}
+	if unknown := h.Flags &^ knownFlags; unknown != 0 {
+		return h, nil, fmt.Errorf("%w: unknown flags %#04x", ErrVersion, uint16(unknown))
+	}
+	return h, nil, nil
+}
+
+// readCoder reads the coder following the header.
+func readCoder(r io.Reader) (Coder, error) {
+	coder := make([]byte, 1)
+	if _, err := io.ReadFull(r, coder); err != nil {
+		return 0, fmt.Errorf("error reading coder %w", unexpected(err))
+	}
+	c := Coder(coder[0])
+	if c == CoderHuffman || c.EntropyCoder() == nil {
+		return 0, fmt.Errorf("%w: unknown coder %d", ErrVersion, c)
+	}
+	return c, nil
+}
+
+// readAlphabet reads the alphabet following the header.
+func readAlphabet(r io.Reader) (Alphabet, error) {
+	alphabet := make([]byte, 1)
+	if _, err := io.ReadFull(r, alphabet); err != nil {
+		return 0, fmt.Errorf("error reading alphabet %w", unexpected(err))
+	}
+	a := Alphabet(alphabet[0])
+	if a == AlphabetBytes || a > AlphabetWords {
+		return 0, fmt.Errorf("%w: unknown alphabet %d", ErrVersion, a)
+	}
+	return a, nil
+}
+
+// writeTransforms writes the transforms following the header.
+func writeTransforms(w io.Writer, chain []Transform) error {
+	buf := []byte{byte(len(chain))}
+	for _, t := range chain {
+		buf = append(buf, byte(t))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readTransforms reads the transforms following the header.
+func readTransforms(r io.Reader) ([]Transform, error) {
+	count := make([]byte, 1)
+	if _, err := io.ReadFull(r, count); err != nil {
+		return nil, fmt.Errorf("error reading transforms %w", unexpected(err))
+	}
+	if count[0] == 0 || int(count[0]) >= len(transforms) {
+		return nil, fmt.Errorf("%w: %d transforms", ErrFormat, count[0])
+	}
+	chain := make([]byte, count[0])
+	if _, err := io.ReadFull(r, chain); err != nil {
+		return nil, fmt.Errorf("error reading transforms %w", unexpected(err))
+	}
+
+	transforms := make([]Transform, len(chain))
+	for i, t := range chain {
+		transforms[i] = Transform(t)
+	}
+	if err := checkTransforms(transforms); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVersion, err)
+	}
+	return transforms, nil
+}
+
+// trailer records the length and checksum of the original data.
+type trailer struct {
+	length   uint64
+	checksum uint32
+}
+
+func writeTrailer(w io.Writer, t trailer) error {
+	buf := make([]byte, 0, trailerSize)
+	buf = binary.BigEndian.AppendUint64(buf, t.length)
+	buf = binary.BigEndian.AppendUint32(buf, t.checksum)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readTrailer(r io.Reader) (trailer, error) {
+	buf := make([]byte, trailerSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return trailer{}, fmt.Errorf("error reading trailer %w", err)
+	}
+	return trailer{
+		length:   binary.BigEndian.Uint64(buf),
+		checksum: binary.BigEndian.Uint32(buf[8:]),
+	}, nil
+}
+
+// verify compares the trailer with the length and CRC-32 of the decoded data.
+func (t trailer) verify(length uint64, checksum uint32) error {
+	if t.length != length {
+		return &CorruptionError{Field: "length", Expected: t.length, Actual: length}
+	}
+	if t.checksum != checksum {
+		return &CorruptionError{Field: "checksum", Expected: uint64(t.checksum), Actual: uint64(checksum)}
+	}
+	return nil
+}
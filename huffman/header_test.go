@@ -0,0 +1,344 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// encodeLegacy encodes data the way streams were before format version 1:
+// the tree size, the tree and the bitstream of the codes of tree.
+func encodeLegacy(tree *Node, data []byte) []byte {
+	var buf bytes.Buffer
+	w := NewBitWriter(&buf)
+	tree_size := w.WriteTree(tree)
+	for _, b := range data {
+		var path []uint8
+		findPath(tree, b, &path)
+		w.WriteMultipleBits(path...)
+	}
+	w.Flush()
+
+	tree_size_bytes, _ := intToBytes(tree_size)
+	return append(tree_size_bytes, buf.Bytes()...)
+}
+
+// encodeVersion1 encodes data the way streams were in format version 1:
+// blocks without a symbol count, ending with a trailing cursor byte.
+func encodeVersion1(data []byte, block_size int) []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, Header{Version: 1, Flags: FlagChecksum})
+	for start := 0; start < len(data); start += block_size {
+		block := data[start:min(start+block_size, len(data))]
+		codes := NewCanonicalCodeTable(CodeLengths(BuildTree(block)))
+
+		var body bytes.Buffer
+		w := NewBitWriter(&body)
+		w.WriteCodeLengths(codes.Lengths())
+		for _, b := range block {
+			w.WriteCode(codes[b])
+		}
+		w.Flush()
+
+		block_size_bytes, _ := intToBytes(uint32(body.Len()))
+		buf.Write(block_size_bytes)
+		buf.Write(body.Bytes())
+	}
+	end, _ := intToBytes(0)
+	buf.Write(end)
+	writeTrailer(&buf, trailer{length: uint64(len(data)), checksum: crc32.ChecksumIEEE(data)})
+	return buf.Bytes()
+}
+
+func TestReadHeader(t *testing.T) {
+	encoded, _ := Encode([]byte("huffman coding"))
+
+	if !bytes.HasPrefix(encoded, Magic[:]) {
+		t.Fatalf("encoded stream %v doesn't start with %v", encoded[:4], Magic)
+	}
+
+	zr := NewReader(bytes.NewReader(encoded))
+	io.ReadAll(zr)
+	expected_header := Header{Version: FormatVersion, Flags: FlagChecksum}
+	if zr.Header() == nil || *zr.Header() != expected_header {
+		t.Fatalf(`Test Read Header Failed.
+		Got: %+v,
+		Wanted: %+v`, zr.Header(), expected_header)
+	}
+}
+
+func TestFlagsString(t *testing.T) {
+	type test_case struct {
+		description string
+		flags       Flags
+		expected    string
+	}
+
+	test_cases := []test_case{
+		{
+			description: "no flags",
+			flags:       0,
+			expected:    "none",
+		},
+		{
+			description: "single flag",
+			flags:       FlagChecksum,
+			expected:    "checksum",
+		},
+		{
+			description: "several flags",
+			flags:       FlagChecksum | FlagCoder | FlagInterleaved,
+			expected:    "checksum|coder|interleaved",
+		},
+		{
+			description: "unknown flags",
+			flags:       FlagOrder1 | 0x8000,
+			expected:    "order1|0x8000",
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			got := scenario.flags.String()
+
+			if got != scenario.expected {
+				t.Fatalf(`Test %d Failed.
+				Got: %q,
+				Wanted: %q`, scenarioIdx, got, scenario.expected)
+			}
+		})
+	}
+}
+
+func TestReadLegacyStream(t *testing.T) {
+	type test_case struct {
+		description string
+		tree        *Node
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "mock tree",
+			tree:        MOCK_TREE,
+			data:        []byte("ACEDBBCAACE"),
+		},
+		{
+			description: "mock tree 2",
+			tree:        MOCK_TREE_2,
+			data:        []byte("FFFFFAACEDBBCAACE"),
+		},
+		{
+			description: "single-noded tree",
+			tree:        &Node{ch: 'A'},
+			data:        []byte("AAAAAAAAAAAAAAAAA"),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			zr := NewReader(bytes.NewReader(encodeLegacy(scenario.tree, scenario.data)))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Version != LegacyVersion {
+				t.Fatalf(`Test %d Failed.
+				Got: %q, %v, header %+v,
+				Wanted: %q, <nil>, version %d`, scenarioIdx, decoded, err, zr.Header(), scenario.data, LegacyVersion)
+			}
+		})
+	}
+}
+
+func TestReadVersion1Stream(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single repeating character",
+			data:        []byte("aaa"),
+			block_size:  MinBlockSize,
+		},
+		{
+			description: "text",
+			data:        []byte("huffman coding and decoding in golang"),
+			block_size:  MinBlockSize,
+		},
+		{
+			description: "several blocks",
+			data:        randomData(3*MinBlockSize+17, 40, 1),
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			zr := NewReader(bytes.NewReader(encodeVersion1(scenario.data, scenario.block_size)))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Version != 1 {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, header %+v,
+				Wanted: %d bytes, <nil>, version 1`, scenarioIdx, len(decoded), err, zr.Header(), len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestSymbolCount(t *testing.T) {
+	// a single byte gets a 1-bit code: the padding of the last byte of the
+	// bitstream would decode as more of it without the symbol count
+	for size := 1; size <= 16; size++ {
+		data := bytes.Repeat([]byte("a"), size)
+		encoded, _ := Encode(data)
+		decoded, err := Decode(encoded)
+
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf(`Test %d Failed.
+			Got: %q, %v,
+			Wanted: %q, <nil>`, size, decoded, err, data)
+		}
+	}
+}
+
+func TestSymbolCount_ShouldFail(t *testing.T) {
+	data := []byte("huffman coding and decoding in golang")
+	encoded, _ := Encode(data)
+	// the symbol count is the first byte of the first block
+	count_idx := headerSize + 4
+
+	type test_case struct {
+		description string
+		count       byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "more symbols than the block holds",
+			count:       byte(len(data) + 8),
+		},
+		{
+			description: "fewer symbols than the block holds",
+			count:       byte(len(data) - 1),
+		},
+		{
+			description: "empty block",
+			count:       0,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := append([]byte{}, encoded...)
+			tampered[count_idx] = scenario.count
+
+			decoded, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: %q, <nil>,
+				Wanted: error`, scenarioIdx, decoded)
+			}
+		})
+	}
+}
+
+func TestReadHeader_ShouldFail(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		expected    error
+	}
+
+	test_cases := []test_case{
+		{
+			description: "foreign file",
+			data:        []byte("hello world, this is not huffman-encoded"),
+			expected:    ErrFormat,
+		},
+		{
+			description: "shorter than the header",
+			data:        []byte("HUF"),
+			expected:    ErrFormat,
+		},
+		{
+			description: "magic without version",
+			data:        []byte("HUFF"),
+			expected:    ErrFormat,
+		},
+		{
+			description: "newer version",
+			data:        append(Magic[:], FormatVersion+1, 0, 0, 0, 0, 0, 0),
+			expected:    ErrVersion,
+		},
+		{
+			description: "unknown flags",
+			data:        append(Magic[:], FormatVersion, 0x80, 0, 0, 0, 0, 0),
+			expected:    ErrVersion,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			_, err := io.ReadAll(NewReader(bytes.NewReader(scenario.data)))
+
+			if !errors.Is(err, scenario.expected) {
+				t.Fatalf(`Test %d Failed.
+				Got: err %v,
+				Wanted: err %v`, scenarioIdx, err, scenario.expected)
+			}
+		})
+	}
+}
+
+func TestChecksum_ShouldFail(t *testing.T) {
+	// all 4 bytes get a 2-bit code: a flipped bit changes one byte, and
+	// not the length of the decoded data
+	data := bytes.Repeat([]byte("abcd"), 2500)
+	encoded, _ := Encode(data)
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte)
+		field       string
+	}
+
+	test_cases := []test_case{
+		{
+			description: "wrong length in trailer",
+			tamper:      func(encoded []byte) { encoded[len(encoded)-trailerSize+7]++ },
+			field:       "length",
+		},
+		{
+			description: "wrong checksum in trailer",
+			tamper:      func(encoded []byte) { encoded[len(encoded)-1]++ },
+			field:       "checksum",
+		},
+		{
+			description: "flipped bit in the bitstream",
+			tamper:      func(encoded []byte) { encoded[len(encoded)/2] ^= 0b0001_0000 },
+			field:       "checksum",
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := append([]byte{}, encoded...)
+			scenario.tamper(tampered)
+
+			_, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			var corruption_err *CorruptionError
+			if !errors.As(err, &corruption_err) || corruption_err.Field != scenario.field {
+				t.Fatalf(`Test %d Failed.
+				Got: err %v,
+				Wanted: %s mismatch`, scenarioIdx, err, scenario.field)
+			}
+		})
+	}
+}
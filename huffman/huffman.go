@@ -0,0 +1,388 @@
+// Package huffman implements Huffman coding of byte streams.
+//
+// The input is split into blocks, each coded with its own tree so that codes
+// follow the statistics of the data around them. An encoded stream starts
+// with a header made of Magic, the format version and 2 bytes of flags,
+// followed by a sequence of blocks, each prefixed with its size as a 4-byte
+// big-endian integer, and ends with a zero size. With FlagChecksum, the zero
+// size is followed by a trailer holding the length and the CRC-32 of the
+// original data, checked by Reader. Streams without a header are read as
+// legacy streams, a single tree and bitstream. With FlagAdaptive, the header
+// is followed by a single bitstream coded with an adaptive tree instead of
+// blocks. With FlagOrder1, blocks hold a code lengths table per preceding
+// byte instead of a single one. With FlagMultiTable, blocks hold up to
+// MaxTables tables, and a selector for each segment of 50 bytes telling
+// which one codes it. With FlagLZ77, blocks are coded as LZ77 literals and
+// matches. With FlagTransforms, blocks are transformed before they're coded,
+// by the Burrows-Wheeler transform, move-to-front and run-length coding of
+// zeros, the transforms being listed after the header. With FlagSymbols,
+// blocks are coded as symbols of several bytes, such as words, each block
+// holding the dictionary of its symbols. With FlagInterleaved, blocks are
+// coded as 4 bitstreams.
+//
+// An Encoder can also write DEFLATE streams (RFC 1951), raw or in gzip or
+// zlib framing, for any DEFLATE decoder: see Format.
+//
+// Blocks use canonical Huffman codes, so a block only needs to store the
+// code length of each byte: it's laid out as the number of symbols it holds
+// as a varint, the code lengths table, and the encoded bitstream padded to a
+// whole byte. Blocks of format version 1 have no symbol count, they end with
+// a byte holding the number of bits used in the last byte of the bitstream.
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	MinBlockSize     int = 64 << 10
+	MaxBlockSize     int = 4 << 20
+	DefaultBlockSize int = 1 << 20
+
+	// MinCodeLength is the smallest code length limit, short enough for
+	// all 256 bytes to have a code.
+	MinCodeLength int = 8
+	MaxCodeLength int = maxCodeLength
+)
+
+// Encoder encodes data using Huffman codes built from the data itself.
+type Encoder struct {
+	// BlockSize is the number of input bytes coded with the same tree,
+	// between MinBlockSize and MaxBlockSize. Zero means DefaultBlockSize.
+	BlockSize int
+
+	// MaxCodeLength caps the length of codes, between MinCodeLength and
+	// MaxCodeLength. Zero means codes are as long as the Huffman tree of a
+	// block makes them: a code of n bits takes at least Fibonacci(n+2)
+	// symbols, so skewed blocks of MaxBlockSize bytes reach 31 bits, and 32
+	// once transforms grow them.
+	MaxCodeLength int
+
+	// Adaptive codes the input in a single pass with a tree updated after
+	// each byte, as both ends of the stream see it, instead of blocks coded
+	// with a stored tree. Input is then encoded as soon as it's written.
+	// BlockSize and MaxCodeLength don't apply.
+	Adaptive bool
+
+	// Order1 codes each byte with a table chosen by the byte before it,
+	// instead of a single table per block. Contexts too rare to pay for
+	// their own table share one. Doesn't apply in adaptive mode.
+	Order1 bool
+
+	// Tables is the number of code tables of a block, up to MaxTables, each
+	// segment of 50 bytes choosing the one coding it best. Zero or one means
+	// a single table per block. Doesn't apply in adaptive and order-1 modes.
+	Tables int
+
+	// Level enables LZ77 matching before Huffman coding, from 1, fastest,
+	// to MaxLevel, strongest: repeated strings are replaced by matches of
+	// earlier bytes of the block. Zero means Huffman coding only. Doesn't
+	// apply in adaptive, order-1 and multi-table modes, and MaxCodeLength
+	// doesn't apply to it: its codes are up to 15 bits.
+	Level int
+
+	// Window is how far back matches can start, a power of two between
+	// MinWindow and MaxWindow. Zero means DefaultWindow.
+	Window int
+
+	// Format is the format of the encoded stream. With a DEFLATE format,
+	// BlockSize, Level and Window apply, and codes are up to 15 bits.
+	Format Format
+
+	// Transforms are applied to each block before it's coded, in order, and
+	// inverted when decoding, each at most once: TransformBWT,
+	// TransformMTF then TransformRLE is the bzip2 pipeline. Doesn't apply
+	// in adaptive mode and DEFLATE formats.
+	Transforms []Transform
+
+	// Coder is the entropy coder of the blocks, CoderHuffman by default.
+	// The other coders don't apply in adaptive, order-1, multi-table and
+	// LZ77 modes, and DEFLATE formats.
+	Coder Coder
+
+	// Alphabet is how blocks are split into symbols, AlphabetBytes by
+	// default. Other alphabets code blocks with the codes of a dictionary
+	// of their symbols, and don't apply in adaptive, order-1, multi-table
+	// and LZ77 modes, with other coders than Huffman and in DEFLATE
+	// formats. MaxCodeLength doesn't apply to them.
+	Alphabet Alphabet
+
+	// Interleaved codes each block as 4 bitstreams, a quarter of the block
+	// each, which the decoder decodes side by side to keep several decodes
+	// in flight, at the cost of a few bytes per block. Doesn't apply in
+	// adaptive, order-1, multi-table and LZ77 modes, with other coders than
+	// Huffman and alphabets other than bytes, and DEFLATE formats.
+	Interleaved bool
+
+	// Concurrency is the number of blocks encoded at once, each on a
+	// goroutine, the output staying the same. Up to Concurrency blocks of
+	// input and their encodings are held in memory. Zero or one encodes
+	// blocks one after the other, runtime.GOMAXPROCS(0) keeps every core
+	// busy. Doesn't apply in adaptive mode and DEFLATE formats.
+	Concurrency int
+
+	// Overwrite lets EncodeFile replace an existing output file.
+	Overwrite bool
+
+	tree  *Node
+	codes CodeTable
+}
+
+// Tree returns the tree of the last block encoded, nil in adaptive, order-1,
+// multi-table and LZ77 modes, with other coders than Huffman and alphabets
+// other than bytes.
+func (e *Encoder) Tree() *Node {
+	return e.tree
+}
+
+// Codes returns the code table of the last block encoded, empty in adaptive,
+// order-1 and LZ77 modes and with alphabets other than bytes, the table of
+// its first segment with several tables.
+func (e *Encoder) Codes() CodeTable {
+	return e.codes
+}
+
+// Check validates the settings of e, returning the error encoding with them
+// would fail with.
+func (e *Encoder) Check() error {
+	if e.BlockSize != 0 && (e.BlockSize < MinBlockSize || e.BlockSize > MaxBlockSize) {
+		return fmt.Errorf("huffman: block size %d out of range [%d, %d]", e.BlockSize, MinBlockSize, MaxBlockSize)
+	}
+	if e.MaxCodeLength != 0 && (e.MaxCodeLength < MinCodeLength || e.MaxCodeLength > MaxCodeLength) {
+		return fmt.Errorf("huffman: max code length %d out of range [%d, %d]", e.MaxCodeLength, MinCodeLength, MaxCodeLength)
+	}
+	if e.Tables < 0 || e.Tables > MaxTables {
+		return fmt.Errorf("huffman: table count %d out of range [0, %d]", e.Tables, MaxTables)
+	}
+	if e.Level < 0 || e.Level > MaxLevel {
+		return fmt.Errorf("huffman: level %d out of range [0, %d]", e.Level, MaxLevel)
+	}
+	if e.Window != 0 && (e.Window < MinWindow || e.Window > MaxWindow || e.Window&(e.Window-1) != 0) {
+		return fmt.Errorf("huffman: window %d isn't a power of two in range [%d, %d]", e.Window, MinWindow, MaxWindow)
+	}
+	if e.Format > FormatZlib {
+		return fmt.Errorf("huffman: unknown format %d", e.Format)
+	}
+	if err := checkTransforms(e.Transforms); err != nil {
+		return err
+	}
+	if e.Coder.EntropyCoder() == nil {
+		return fmt.Errorf("huffman: unknown coder %d", e.Coder)
+	}
+	if e.Alphabet > AlphabetWords {
+		return fmt.Errorf("huffman: unknown alphabet %d", e.Alphabet)
+	}
+	if e.Concurrency < 0 {
+		return fmt.Errorf("huffman: negative concurrency %d", e.Concurrency)
+	}
+
+	modes := e.modes()
+	if len(modes) > 1 {
+		return fmt.Errorf("huffman: %s can't be combined", strings.Join(modes, " and "))
+	}
+	if e.Format != FormatHuffman && (len(modes) > 0 && e.Level == 0 || len(e.Transforms) > 0) {
+		return errors.New("huffman: DEFLATE formats can't be combined with other modes than LZ77 or with transforms")
+	}
+	if e.Adaptive && len(e.Transforms) > 0 {
+		return errors.New("huffman: transforms can't be combined with adaptive mode")
+	}
+	return nil
+}
+
+// modes returns the modes set in e that code blocks in a layout of their
+// own, which exclude each other.
+func (e *Encoder) modes() []string {
+	var modes []string
+	for _, mode := range []struct {
+		name string
+		set  bool
+	}{
+		{"adaptive mode", e.Adaptive},
+		{"order-1 mode", e.Order1},
+		{"several tables", e.Tables > 1},
+		{"LZ77", e.Level > 0},
+		{"interleaved streams", e.Interleaved},
+		{fmt.Sprintf("%v coder", e.Coder), e.Coder != CoderHuffman},
+		{fmt.Sprintf("%v alphabet", e.Alphabet), e.Alphabet != AlphabetBytes},
+	} {
+		if mode.set {
+			modes = append(modes, mode.name)
+		}
+	}
+	return modes
+}
+
+func (e *Encoder) blockSize() int {
+	if e.BlockSize == 0 {
+		return DefaultBlockSize
+	}
+	return e.BlockSize
+}
+
+// Encode writes the Huffman encoding of data to w.
+// Returns the number of bytes written to w.
+func (e *Encoder) Encode(w io.Writer, data []byte) (int, error) {
+	out := &countWriter{w: w}
+	zw := e.NewWriter(out)
+	if _, err := zw.Write(data); err != nil {
+		return int(out.n), err
+	}
+	err := zw.Close()
+	return int(out.n), err
+}
+
+// encodeBlock writes data as a single block, without its size prefix.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeBlock(w io.Writer, data []byte) (int, error) {
+	data = applyTransforms(e.Transforms, data)
+	if e.Order1 {
+		return e.encodeContextBlock(w, data)
+	}
+	if e.Tables > 1 {
+		return e.encodeMultiTableBlock(w, data)
+	}
+	if e.Level > 0 {
+		return e.encodeLZBlock(w, data)
+	}
+	if e.Alphabet != AlphabetBytes {
+		return e.encodeSymbolBlock(w, data)
+	}
+	if e.Interleaved {
+		return e.encodeInterleavedBlock(w, data)
+	}
+
+	n, err := w.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	if err != nil {
+		return n, err
+	}
+
+	if e.Coder == CoderHuffman {
+		c := HuffmanCoder{MaxCodeLength: e.MaxCodeLength}
+		written, err := c.Encode(w, data)
+		e.tree, e.codes = c.tree, c.codes
+		return n + written, err
+	}
+	written, err := e.Coder.EntropyCoder().Encode(w, data)
+	e.tree, e.codes = nil, CodeTable{}
+	return n + written, err
+}
+
+// Encodes a file into using Huffman encoding
+// The output is written to a temporary file renamed to outputFile once it's
+// complete, with the permissions and modification time of inputFile. An
+// existing outputFile is an error wrapping fs.ErrExist, unless Overwrite is set.
+// Returns ratio of outputsize / inputsize, 0 for an empty input, and whatever error that may have resulted
+func (e *Encoder) EncodeFile(inputFile string, outputFile string) (float64, error) {
+	in, read_err := os.Open(inputFile)
+	if read_err != nil {
+		return 0, read_err
+	}
+	defer in.Close()
+	info, stat_err := in.Stat()
+	if stat_err != nil {
+		return 0, stat_err
+	}
+
+	var n, written int64
+	write_err := writeFile(outputFile, e.Overwrite, info, func(f io.Writer) error {
+		out := &countWriter{w: f}
+		zw := e.NewWriter(out)
+		var copy_err error
+		if n, copy_err = io.Copy(zw, in); copy_err != nil {
+			return copy_err
+		}
+		close_err := zw.Close()
+		written = out.n
+		return close_err
+	})
+	if write_err != nil {
+		return 0, write_err
+	}
+
+	return ratio(written, n), nil
+}
+
+// Decoder decodes data produced by an Encoder.
+type Decoder struct {
+	// Concurrency is the number of blocks decoded at once, each on a
+	// goroutine, read ahead of the output. Zero or one decodes blocks one
+	// after the other. Doesn't apply to legacy, version 1 and adaptive
+	// streams, and DEFLATE formats.
+	Concurrency int
+
+	// Format is FormatDeflate to read raw DEFLATE streams, which have no
+	// header to recognize them by. Streams of the other formats are
+	// recognized whatever Format is.
+	Format Format
+
+	// Overwrite lets DecodeFile replace an existing output file.
+	Overwrite bool
+
+	tree *Node
+}
+
+// Tree returns the tree of the last block decoded, nil for blocks decoded
+// in parallel.
+func (d *Decoder) Tree() *Node {
+	return d.tree
+}
+
+// Decode writes the decoding of the Huffman-encoded data to w.
+// Returns the number of bytes written to w.
+func (d *Decoder) Decode(w io.Writer, data []byte) (int, error) {
+	zr := d.NewReader(bytes.NewReader(data))
+	n, err := io.Copy(w, zr)
+	d.tree = zr.Tree()
+	return int(n), err
+}
+
+// Decodes huffman-encoded input file to output file.
+// The output is written like EncodeFile's, outputFile being left as it was
+// if inputFile doesn't decode.
+// Returns ratio of outputsize / inputsize written to outputFile, 0 for an empty output, and whatever error that may have resulted
+func (d *Decoder) DecodeFile(inputFile string, outputFile string) (float64, error) {
+	in, read_err := os.Open(inputFile)
+	if read_err != nil {
+		return 0, read_err
+	}
+	defer in.Close()
+	info, stat_err := in.Stat()
+	if stat_err != nil {
+		return 0, stat_err
+	}
+
+	src := &countReader{r: in}
+	var n int64
+	write_err := writeFile(outputFile, d.Overwrite, info, func(f io.Writer) error {
+		zr := d.NewReader(src)
+		var decode_err error
+		n, decode_err = io.Copy(f, zr)
+		d.tree = zr.Tree()
+		return decode_err
+	})
+	if write_err != nil {
+		return 0, write_err
+	}
+
+	return ratio(src.n, n), nil
+}
+
+// Encode returns the Huffman encoding of data.
+func Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := (&Encoder{}).Encode(&buf, data)
+	return buf.Bytes(), err
+}
+
+// Decode returns the decoding of Huffman-encoded data.
+func Decode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := (&Decoder{}).Decode(&buf, data)
+	return buf.Bytes(), err
+}
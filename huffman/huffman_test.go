@@ -0,0 +1,203 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single repeating character",
+			data:        []byte("aaaaaaa"),
+		},
+		{
+			description: "two characters",
+			data:        []byte("abababbbba"),
+		},
+		{
+			description: "text",
+			data:        []byte("huffman coding and decoding in golang"),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := Encoder{}
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			var decoded bytes.Buffer
+			d := Decoder{}
+			_, err := d.Decode(&decoded, encoded.Bytes())
+
+			if err != nil || !bytes.Equal(decoded.Bytes(), scenario.data) || CodeLengths(e.Tree()) != CodeLengths(d.Tree()) {
+				t.Fatalf(`Test %d Failed.
+				Got: %q, %v,
+				Wanted: %q, <nil>`, scenarioIdx, decoded.Bytes(), err, scenario.data)
+			}
+		})
+	}
+}
+
+// encoders of every mode, for input of every size to go through
+var tinyInputEncoders = []Encoder{
+	{},
+	{Adaptive: true},
+	{Order1: true},
+	{Tables: 3},
+	{Level: 6},
+	{Transforms: bzip2Transforms},
+	{Coder: CoderRange},
+	{Coder: CoderRANS},
+	{Alphabet: AlphabetUint16},
+	{Alphabet: AlphabetWords},
+	{Interleaved: true},
+	{MaxCodeLength: MinCodeLength},
+	{Concurrency: 4},
+	{Format: FormatDeflate},
+	{Format: FormatGzip},
+	{Format: FormatZlib, Level: 6},
+}
+
+func TestTinyInputs(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "empty",
+			data:        []byte{},
+		},
+		{
+			description: "single byte",
+			data:        []byte("a"),
+		},
+		{
+			description: "zero byte",
+			data:        []byte{0},
+		},
+		{
+			description: "two equal bytes",
+			data:        []byte("aa"),
+		},
+		{
+			description: "two bytes",
+			data:        []byte{0xff, 0},
+		},
+		{
+			description: "single symbol file",
+			data:        bytes.Repeat([]byte("a"), MinBlockSize+1),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, e := range tinyInputEncoders {
+				var encoded bytes.Buffer
+				if _, err := e.Encode(&encoded, scenario.data); err != nil {
+					t.Fatalf("Test %d Failed. %+v encode error: %v", scenarioIdx, e, err)
+				}
+
+				zr := (&Decoder{Concurrency: e.Concurrency}).NewReader(iotest.OneByteReader(&encoded))
+				if e.Format == FormatDeflate {
+					zr = (&Decoder{Format: FormatDeflate}).NewReader(iotest.OneByteReader(&encoded))
+				}
+				decoded, err := io.ReadAll(zr)
+
+				if err != nil || !bytes.Equal(decoded, scenario.data) {
+					t.Fatalf(`Test %d Failed. %+v
+					Got: %q, %v,
+					Wanted: %q, <nil>`, scenarioIdx, e, decoded, err, scenario.data)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncated_ShouldFail(t *testing.T) {
+	for _, data := range [][]byte{{}, []byte("a"), []byte("ab")} {
+		for _, e := range tinyInputEncoders {
+			if e.Format == FormatDeflate {
+				// raw DEFLATE has no header to tell a truncated stream apart
+				continue
+			}
+			var encoded bytes.Buffer
+			e.Encode(&encoded, data)
+
+			for size := 0; size < encoded.Len(); size++ {
+				decoded, err := io.ReadAll(NewReader(bytes.NewReader(encoded.Bytes()[:size])))
+
+				if err == nil {
+					t.Fatalf(`Test %q %+v Failed. truncated to %d of %d bytes
+					Got: %q, err <nil>,
+					Wanted: err != <nil>`, data, e, size, encoded.Len(), decoded)
+				}
+				if size < headerSize && e.Format == FormatHuffman && !errors.Is(err, ErrFormat) {
+					t.Fatalf(`Test %q %+v Failed. truncated to %d bytes
+					Got: %v,
+					Wanted: %v`, data, e, size, err, ErrFormat)
+				}
+			}
+		}
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "empty")
+	os.WriteFile(input, nil, 0600)
+
+	ratio, err := (&Encoder{}).EncodeFile(input, filepath.Join(dir, "empty.huff"))
+	if err != nil || ratio != 0 {
+		t.Fatalf(`Test Encode Failed.
+		Got: ratio %v, %v,
+		Wanted: ratio 0, <nil>`, ratio, err)
+	}
+	encoded, _ := os.ReadFile(filepath.Join(dir, "empty.huff"))
+	// the header, the end of the blocks and the trailer
+	if len(encoded) != headerSize+4+trailerSize {
+		t.Fatalf(`Test Size Failed.
+		Got: %d bytes,
+		Wanted: %d bytes`, len(encoded), headerSize+4+trailerSize)
+	}
+
+	ratio, err = (&Decoder{}).DecodeFile(filepath.Join(dir, "empty.huff"), filepath.Join(dir, "decoded"))
+	decoded, _ := os.ReadFile(filepath.Join(dir, "decoded"))
+	if err != nil || ratio != 0 || len(decoded) != 0 {
+		t.Fatalf(`Test Decode Failed.
+		Got: ratio %v, %d bytes, %v,
+		Wanted: ratio 0, 0 bytes, <nil>`, ratio, len(decoded), err)
+	}
+
+	if _, err := (&Decoder{}).DecodeFile(input, filepath.Join(dir, "not-decoded")); !errors.Is(err, ErrFormat) {
+		t.Fatalf(`Test Decode Empty Failed.
+		Got: %v,
+		Wanted: %v`, err, ErrFormat)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			b.SetBytes(int64(len(corpus.data)))
+			for i := 0; i < b.N; i++ {
+				(&Encoder{}).Encode(io.Discard, corpus.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"math/bits"
+)
+
+// gzip header flags
+const (
+	gzipHeaderCRC uint8 = 1 << 1
+	gzipExtra     uint8 = 1 << 2
+	gzipName      uint8 = 1 << 3
+	gzipComment   uint8 = 1 << 4
+)
+
+// ErrDeflate is returned when reading an invalid DEFLATE, gzip or zlib
+// stream.
+var ErrDeflate = errors.New("huffman: invalid DEFLATE stream")
+
+// fixed code lengths of the literal/length and distance alphabets, for
+// blocks coded with the fixed codes
+var fixedLiterals, fixedDistances = func() (*decodeTable, *decodeTable) {
+	literal_lengths := make([]uint8, 288)
+	for symbol := range literal_lengths {
+		switch {
+		case symbol < 144:
+			literal_lengths[symbol] = 8
+		case symbol < 256:
+			literal_lengths[symbol] = 9
+		case symbol < 280:
+			literal_lengths[symbol] = 7
+		default:
+			literal_lengths[symbol] = 8
+		}
+	}
+	distance_lengths := bytes.Repeat([]byte{5}, 32)
+
+	literals, _ := newCanonicalDecodeTable(literal_lengths)
+	distances, _ := newCanonicalDecodeTable(distance_lengths)
+	return literals, distances
+}()
+
+// inflater decodes the blocks of a DEFLATE stream.
+type inflater struct {
+	lzDecoder
+	stored int  // bytes left in the current stored block
+	final  bool // the current block is the last one
+}
+
+func (z *Reader) setFormat(format Format) {
+	z.format = format
+	z.inflate = &inflater{lzDecoder: lzDecoder{deflate: true}}
+	if format == FormatZlib {
+		z.digest = adler32.New()
+	}
+}
+
+// Format returns the format of the stream, known after the first call to
+// Read.
+func (z *Reader) Format() Format {
+	return z.format
+}
+
+// detectFormat recognizes gzip and zlib streams from their first 2 bytes,
+// which are left to read.
+func (z *Reader) detectFormat() error {
+	prefix := make([]byte, 2)
+	n, err := io.ReadFull(z.r, prefix)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	prefix = prefix[:n]
+	switch {
+	case bytes.Equal(prefix, []byte{0x1f, 0x8b}):
+		z.setFormat(FormatGzip)
+	case n == 2 && prefix[0]&0x0f == 8 && prefix[0]>>4 <= 7 && binary.BigEndian.Uint16(prefix)%31 == 0:
+		z.setFormat(FormatZlib)
+	}
+	z.r = io.MultiReader(bytes.NewReader(prefix), z.r)
+	return nil
+}
+
+// startDeflate reads the header of the framing, and sets up the bitstream.
+func (z *Reader) startDeflate() error {
+	var err error
+	switch z.format {
+	case FormatGzip:
+		err = readGzipHeader(z.r)
+	case FormatZlib:
+		err = readZlibHeader(z.r)
+	}
+	if err != nil {
+		return err
+	}
+
+	// DEFLATE packs bits least significant first: with the bits of each
+	// byte reversed, Huffman codes read in order with the decode tables,
+	// and only other fields need to be reversed back
+	z.br = NewPaddedBitReader(reversedReader{z.r})
+	return nil
+}
+
+func readGzipHeader(r io.Reader) error {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("error reading gzip header %w", unexpected(err))
+	}
+	if header[0] != 0x1f || header[1] != 0x8b || header[2] != 8 {
+		return fmt.Errorf("%w: not a gzip stream", ErrDeflate)
+	}
+	flags := header[3]
+	if flags&^(gzipHeaderCRC|gzipExtra|gzipName|gzipComment|1) != 0 {
+		return fmt.Errorf("%w: reserved gzip flags %#02x", ErrDeflate, flags)
+	}
+
+	if flags&gzipExtra != 0 {
+		size := make([]byte, 2)
+		if _, err := io.ReadFull(r, size); err != nil {
+			return fmt.Errorf("error reading gzip header %w", unexpected(err))
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(binary.LittleEndian.Uint16(size))); err != nil {
+			return fmt.Errorf("error reading gzip header %w", unexpected(err))
+		}
+	}
+	for _, flag := range []uint8{gzipName, gzipComment} {
+		if flags&flag == 0 {
+			continue
+		}
+		// a zero-terminated string
+		b := []byte{1}
+		for b[0] != 0 {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return fmt.Errorf("error reading gzip header %w", unexpected(err))
+			}
+		}
+	}
+	if flags&gzipHeaderCRC != 0 {
+		if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+			return fmt.Errorf("error reading gzip header %w", unexpected(err))
+		}
+	}
+	return nil
+}
+
+func readZlibHeader(r io.Reader) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("error reading zlib header %w", unexpected(err))
+	}
+	if header[0]&0x0f != 8 || header[0]>>4 > 7 || binary.BigEndian.Uint16(header)%31 != 0 {
+		return fmt.Errorf("%w: not a zlib stream", ErrDeflate)
+	}
+	if header[1]&(1<<5) != 0 {
+		return fmt.Errorf("%w: zlib preset dictionaries are not supported", ErrDeflate)
+	}
+	return nil
+}
+
+// readDeflateSymbol decodes the next byte of a DEFLATE stream, reading
+// block headers as they come. Returns io.EOF at the end of the stream.
+func (z *Reader) readDeflateSymbol() (byte, error) {
+	d := z.inflate
+	for d.match == 0 {
+		if len(d.history) > 2*MaxWindow {
+			// only the last window can be referred to
+			d.history = append(d.history[:0], d.history[len(d.history)-MaxWindow:]...)
+		}
+
+		switch {
+		case d.stored > 0:
+			b, err := z.br.ReadBits(8)
+			if err != nil {
+				return 0, unexpected(err)
+			}
+			d.stored--
+			d.history = append(d.history, bits.Reverse8(byte(b)))
+			return d.history[len(d.history)-1], nil
+		case d.literals != nil:
+			symbol, err := d.literals.decodeSymbol(z.br)
+			if err != nil {
+				return 0, unexpected(err)
+			}
+			if symbol < 256 {
+				d.history = append(d.history, byte(symbol))
+				return byte(symbol), nil
+			}
+			if symbol == endOfBlock {
+				d.literals = nil
+				continue
+			}
+			if err := d.readMatch(z.br, symbol); err != nil {
+				return 0, err
+			}
+		case d.final:
+			return 0, z.endDeflate()
+		default:
+			if err := z.readDeflateBlockHeader(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return d.next(), nil
+}
+
+// readDeflateBlockHeader reads the header of the next block, and its code
+// tables.
+func (z *Reader) readDeflateBlockHeader() error {
+	header, err := z.readExtraBits(3)
+	if err != nil {
+		return fmt.Errorf("error reading block header %w", unexpected(err))
+	}
+	z.inflate.final = header&1 != 0
+
+	switch header >> 1 {
+	case blockStored:
+		z.br.SkipBits(uint8(-z.br.position() & 7))
+		size, err := z.readExtraBits(32)
+		if err != nil {
+			return fmt.Errorf("error reading stored block size %w", unexpected(err))
+		}
+		if uint16(size) != ^uint16(size>>16) {
+			return fmt.Errorf("%w: stored block size %#04x doesn't match its complement %#04x", ErrDeflate, uint16(size), uint16(size>>16))
+		}
+		z.inflate.stored = int(uint16(size))
+		return nil
+	case blockFixed:
+		z.inflate.literals, z.inflate.distances = fixedLiterals, fixedDistances
+		return nil
+	case blockDynamic:
+		return z.readDynamicTables()
+	}
+	return fmt.Errorf("%w: reserved block type 3", ErrDeflate)
+}
+
+// readDynamicTables reads the literal/length and distance tables of a
+// dynamic block, coded with a code length table.
+func (z *Reader) readDynamicTables() error {
+	counts, err := z.readExtraBits(14)
+	if err != nil {
+		return fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	hlit := int(counts&0x1f) + 257
+	hdist := int(counts>>5&0x1f) + 1
+	hclen := int(counts>>10) + 4
+	if hlit > literalLengthSymbols || hdist > distanceSymbols {
+		return fmt.Errorf("%w: %d literal/length and %d distance codes", ErrDeflate, hlit, hdist)
+	}
+
+	code_lengths := make([]uint8, codeLengthSymbols)
+	for _, symbol := range codeLengthOrder[:hclen] {
+		length, err := z.readExtraBits(3)
+		if err != nil {
+			return fmt.Errorf("error reading code lengths %w", unexpected(err))
+		}
+		code_lengths[symbol] = uint8(length)
+	}
+	code_table, err := newCanonicalDecodeTable(code_lengths)
+	if err != nil {
+		return err
+	}
+
+	lengths := make([]uint8, hlit+hdist)
+	for i := 0; i < len(lengths); {
+		symbol, err := code_table.decodeSymbol(z.br)
+		if err != nil {
+			return fmt.Errorf("error reading code lengths %w", unexpected(err))
+		}
+		if symbol < 16 {
+			lengths[i] = uint8(symbol)
+			i++
+			continue
+		}
+
+		// 16 repeats the previous length 3 to 6 times, 17 and 18 repeat a
+		// zero 3 to 10 and 11 to 138 times
+		var length uint8
+		var base int
+		var extra_bits uint8
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return fmt.Errorf("%w: repeat of no code length", ErrDeflate)
+			}
+			length, base, extra_bits = lengths[i-1], 3, 2
+		case 17:
+			base, extra_bits = 3, 3
+		default:
+			base, extra_bits = 11, 7
+		}
+		extra, err := z.readExtraBits(extra_bits)
+		if err != nil {
+			return fmt.Errorf("error reading code lengths %w", unexpected(err))
+		}
+		run := base + int(extra)
+		if i+run > len(lengths) {
+			return fmt.Errorf("%w: run of %d code lengths past the end of the tables", ErrDeflate, run)
+		}
+		for ; run > 0; run-- {
+			lengths[i] = length
+			i++
+		}
+	}
+
+	if lengths[endOfBlock] == 0 {
+		return fmt.Errorf("%w: no end of block code", ErrDeflate)
+	}
+	d := z.inflate
+	if d.literals, err = newCanonicalDecodeTable(lengths[:hlit]); err != nil {
+		return err
+	}
+	d.distances = nil
+	for _, length := range lengths[hlit:] {
+		if length != 0 {
+			d.distances, err = newCanonicalDecodeTable(lengths[hlit:])
+			return err
+		}
+	}
+	return nil
+}
+
+// endDeflate reads the trailer of the framing after the last block. Returns
+// io.EOF, the end of the stream.
+func (z *Reader) endDeflate() error {
+	z.br.SkipBits(uint8(-z.br.position() & 7))
+	switch z.format {
+	case FormatGzip:
+		checksum, err := z.readExtraBits(32)
+		if err != nil {
+			return fmt.Errorf("error reading trailer %w", unexpected(err))
+		}
+		length, err := z.readExtraBits(32)
+		if err != nil {
+			return fmt.Errorf("error reading trailer %w", unexpected(err))
+		}
+		z.end = &trailer{length: length, checksum: uint32(checksum)}
+	case FormatZlib:
+		checksum, err := z.readExtraBits(32)
+		if err != nil {
+			return fmt.Errorf("error reading trailer %w", unexpected(err))
+		}
+		z.end = &trailer{checksum: bits.ReverseBytes32(uint32(checksum))}
+	}
+	return io.EOF
+}
+
+// nextMember checks the trailer of a gzip member, decoded being its output
+// not yet added to the length and digest, then starts the member following
+// it. Returns io.EOF at the end of the stream, and an error for trailing
+// data other than a gzip member.
+func (z *Reader) nextMember(decoded []byte) error {
+	z.length += uint64(len(decoded))
+	z.digest.Write(decoded)
+	if err := z.end.verify(z.trailerLength(), z.digest.Sum32()); err != nil {
+		return err
+	}
+	z.end, z.length = nil, 0
+	z.digest.Reset()
+
+	// bytes the bitstream read ahead of the trailer come first
+	buffered := z.br.buffered()
+	for i := range buffered {
+		buffered[i] = bits.Reverse8(buffered[i])
+	}
+	z.r = io.MultiReader(bytes.NewReader(buffered), z.r)
+	z.br, z.inflate.final, z.inflate.history = nil, false, z.inflate.history[:0]
+
+	prefix := make([]byte, 2)
+	n, err := io.ReadFull(z.r, prefix)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if !bytes.Equal(prefix[:n], []byte{0x1f, 0x8b}) {
+		return fmt.Errorf("%w: trailing data after the gzip stream", ErrDeflate)
+	}
+	z.r = io.MultiReader(bytes.NewReader(prefix), z.r)
+	return nil
+}
+
+// trailerLength returns the length of the decoded data the way the trailer
+// of the stream records it: modulo 2^32 in gzip streams, not at all in zlib
+// streams.
+func (z *Reader) trailerLength() uint64 {
+	switch z.format {
+	case FormatGzip:
+		return z.length & (1<<32 - 1)
+	case FormatZlib:
+		return 0
+	}
+	return z.length
+}
+
+// readExtraBits reads n bits of a DEFLATE stream that aren't a Huffman code.
+func (z *Reader) readExtraBits(n uint8) (uint64, error) {
+	return readExtraBits(z.br, n, true)
+}
+
+// readExtraBits reads n bits that aren't a Huffman code from br: most
+// significant first in this package's format, least significant first in
+// DEFLATE.
+func readExtraBits(br *BitReader, n uint8, deflate bool) (uint64, error) {
+	value, err := br.ReadBits(n)
+	if err != nil || !deflate || n == 0 {
+		return value, err
+	}
+	return bits.Reverse64(value) >> (64 - n), nil
+}
+
+// reversedReader reverses the bits of every byte read from r.
+type reversedReader struct {
+	r io.Reader
+}
+
+func (r reversedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := range p[:n] {
+		p[i] = bits.Reverse8(p[i])
+	}
+	return n, err
+}
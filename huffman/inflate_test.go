@@ -0,0 +1,277 @@
+package huffman
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// deflate encodes data in format with the standard library, at level.
+func deflate(format Format, level int, data []byte) []byte {
+	var encoded bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case FormatDeflate:
+		w, _ = flate.NewWriter(&encoded, level)
+	case FormatGzip:
+		zw, _ := gzip.NewWriterLevel(&encoded, level)
+		zw.Name, zw.Comment, zw.Extra = "data.txt", "a comment", []byte("extra")
+		w = zw
+	case FormatZlib:
+		w, _ = zlib.NewWriterLevel(&encoded, level)
+	}
+	w.Write(data[:len(data)/2])
+	// a sync flush, ending with an empty stored block
+	w.(interface{ Flush() error }).Flush()
+	w.Write(data[len(data)/2:])
+	w.Close()
+	return encoded.Bytes()
+}
+
+func TestInflate(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "empty",
+			data:        []byte{},
+		},
+		{
+			description: "short text, fixed codes",
+			data:        []byte("huffman coding and decoding in golang"),
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 100_000),
+		},
+		{
+			description: "all bytes",
+			data:        randomData(200_000, 256, 26),
+		},
+		{
+			description: "log lines",
+			data:        logData(500_000, 27),
+		},
+	}
+
+	levels := []int{flate.HuffmanOnly, flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression}
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, format := range []Format{FormatDeflate, FormatGzip, FormatZlib} {
+				for _, level := range levels {
+					encoded := deflate(format, level, scenario.data)
+
+					zr := NewReader(iotest.HalfReader(bytes.NewReader(encoded)))
+					if format == FormatDeflate {
+						zr = (&Decoder{Format: format}).NewReader(iotest.HalfReader(bytes.NewReader(encoded)))
+					}
+					decoded, err := io.ReadAll(zr)
+
+					if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Format() != format {
+						t.Fatalf(`Test %d Failed. format %d level %d
+						Got: %d bytes, %v, format %d,
+						Wanted: %d bytes, <nil>, format %d`, scenarioIdx, format, level, len(decoded), err, zr.Format(), len(scenario.data), format)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInflateOwnStreams(t *testing.T) {
+	data := logData(3*MinBlockSize+17, 28)
+	for _, format := range []Format{FormatDeflate, FormatGzip, FormatZlib} {
+		var encoded bytes.Buffer
+		(&Encoder{BlockSize: MinBlockSize, Level: 6, Format: format}).Encode(&encoded, data)
+
+		decoded, err := io.ReadAll((&Decoder{Format: format}).NewReader(&encoded))
+
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf(`Test format %d Failed.
+			Got: %d bytes, %v,
+			Wanted: %d bytes, <nil>`, format, len(decoded), err, len(data))
+		}
+	}
+}
+
+func TestInflateGzipMembers(t *testing.T) {
+	type test_case struct {
+		description string
+		members     [][]byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "two members",
+			members:     [][]byte{[]byte("hello world"), []byte("!!")},
+		},
+		{
+			description: "empty member",
+			members:     [][]byte{[]byte("hello"), {}, []byte(" world")},
+		},
+		{
+			description: "many members",
+			members:     bytes.SplitAfter(logData(100_000, 56), []byte("\n")),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			for i, member := range scenario.members {
+				// stored, fixed and dynamic blocks end at any bit
+				encoded.Write(deflate(FormatGzip, i%3-1, member))
+			}
+			data := bytes.Join(scenario.members, nil)
+
+			for _, r := range []io.Reader{bytes.NewReader(encoded.Bytes()), iotest.OneByteReader(bytes.NewReader(encoded.Bytes()))} {
+				decoded, err := io.ReadAll(NewReader(r))
+
+				if err != nil || !bytes.Equal(decoded, data) {
+					t.Fatalf(`Test %d Failed.
+					Got: %d bytes, %v,
+					Wanted: %d bytes, <nil>`, scenarioIdx, len(decoded), err, len(data))
+				}
+			}
+		})
+	}
+}
+
+func TestInflate_ShouldFail(t *testing.T) {
+	data := logData(10_000, 29)
+	gzipped := deflate(FormatGzip, flate.DefaultCompression, data)
+	zlibbed := deflate(FormatZlib, flate.DefaultCompression, data)
+	stored := deflate(FormatDeflate, flate.NoCompression, data)
+
+	type test_case struct {
+		description string
+		encoded     []byte
+		format      Format
+		tamper      func(encoded []byte) []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "truncated gzip stream",
+			encoded:     gzipped,
+			format:      FormatGzip,
+			tamper:      func(encoded []byte) []byte { return encoded[:len(encoded)-20] },
+		},
+		{
+			description: "truncated gzip trailer",
+			encoded:     gzipped,
+			format:      FormatGzip,
+			tamper:      func(encoded []byte) []byte { return encoded[:len(encoded)-2] },
+		},
+		{
+			description: "gzip checksum",
+			encoded:     gzipped,
+			format:      FormatGzip,
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-8] ^= 1
+				return encoded
+			},
+		},
+		{
+			description: "gzip length",
+			encoded:     gzipped,
+			format:      FormatGzip,
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-4] ^= 1
+				return encoded
+			},
+		},
+		{
+			description: "trailing data after the gzip stream",
+			encoded:     gzipped,
+			format:      FormatGzip,
+			tamper:      func(encoded []byte) []byte { return append(encoded, "trailing"...) },
+		},
+		{
+			description: "second gzip member checksum",
+			encoded:     append(deflate(FormatGzip, flate.BestSpeed, data[:100]), gzipped...),
+			format:      FormatGzip,
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-8] ^= 1
+				return encoded
+			},
+		},
+		{
+			description: "zlib checksum",
+			encoded:     zlibbed,
+			format:      FormatZlib,
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-1] ^= 1
+				return encoded
+			},
+		},
+		{
+			description: "zlib preset dictionary",
+			encoded:     zlibbed,
+			format:      FormatZlib,
+			tamper: func(encoded []byte) []byte {
+				encoded[1] = encoded[1]&^0x1f | 1<<5
+				encoded[1] += uint8(31-binary.BigEndian.Uint16(encoded)%31) % 31
+				return encoded
+			},
+		},
+		{
+			description: "reserved block type",
+			encoded:     []byte{0b111},
+			format:      FormatDeflate,
+			tamper:      func(encoded []byte) []byte { return encoded },
+		},
+		{
+			description: "stored block size complement",
+			encoded:     stored,
+			format:      FormatDeflate,
+			tamper: func(encoded []byte) []byte {
+				encoded[3] ^= 1
+				return encoded
+			},
+		},
+		{
+			description: "match before the start of the stream",
+			// a fixed block: a match of length 3 at distance 1
+			encoded: []byte{0x03, 0x02},
+			format:  FormatDeflate,
+			tamper:  func(encoded []byte) []byte { return encoded },
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := scenario.tamper(append([]byte{}, scenario.encoded...))
+
+			_, err := io.ReadAll((&Decoder{Format: scenario.format}).NewReader(bytes.NewReader(tampered)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
+
+func BenchmarkInflate(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			encoded := deflate(FormatGzip, flate.DefaultCompression, corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				io.Copy(io.Discard, NewReader(bytes.NewReader(encoded)))
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package huffman
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LimitedCodeLengths returns optimal code lengths for bytes occuring freqs
+// times, under the constraint that no code is longer than limit bits.
+// Returns an error if limit bits can't code all the bytes that occur, 8 bits
+// being enough for any of them.
+func LimitedCodeLengths(freqs [256]int, limit int) ([256]uint8, error) {
+	var lengths [256]uint8
+	symbols := 0
+	for _, freq := range freqs {
+		if freq > 0 {
+			symbols++
+		}
+	}
+	if symbols > 1 && (limit < 1 || limit < 8 && 1<<limit < symbols) {
+		return lengths, fmt.Errorf("huffman: code length limit %d too small for %d symbols", limit, symbols)
+	}
+	copy(lengths[:], packageMerge(freqs[:], limit))
+	return lengths, nil
+}
+
+// packageMerge computes length-limited code lengths with the package-merge
+// algorithm: each symbol is a coin of width 2^-length for every length from 1
+// to limit, and codes are the cheapest set of coins adding up to n-1, where
+// the number of coins picked for a symbol is its code length.
+// Symbols with a zero frequency get no code, a single symbol gets a 1-bit code.
+func packageMerge(freqs []int, limit int) []uint8 {
+	lengths := make([]uint8, len(freqs))
+
+	// a coin is either a symbol or a package of two coins of the previous list
+	type coin struct {
+		weight      int
+		symbol      int // -1 for packages
+		left, right *coin
+	}
+
+	var leaves []*coin
+	for symbol, freq := range freqs {
+		if freq > 0 {
+			leaves = append(leaves, &coin{weight: freq, symbol: symbol})
+		}
+	}
+	if len(leaves) == 0 {
+		return lengths
+	}
+	if len(leaves) == 1 {
+		lengths[leaves[0].symbol] = 1
+		return lengths
+	}
+	if 1<<min(limit, 62) < len(leaves) {
+		panic("huffman: code length limit too small for the number of symbols")
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return leaves[i].weight < leaves[j].weight
+	})
+
+	list := leaves
+	for level := 1; level < limit; level++ {
+		// package the coins of the list two by two, dropping the last odd one
+		packages := make([]*coin, 0, len(list)/2)
+		for i := 0; i+1 < len(list); i += 2 {
+			packages = append(packages, &coin{
+				weight: list[i].weight + list[i+1].weight,
+				symbol: -1,
+				left:   list[i],
+				right:  list[i+1],
+			})
+		}
+
+		// merge them with the leaves, leaves first on ties
+		merged := make([]*coin, 0, len(leaves)+len(packages))
+		i, j := 0, 0
+		for i < len(leaves) || j < len(packages) {
+			if j == len(packages) || (i < len(leaves) && leaves[i].weight <= packages[j].weight) {
+				merged = append(merged, leaves[i])
+				i++
+			} else {
+				merged = append(merged, packages[j])
+				j++
+			}
+		}
+		list = merged
+	}
+
+	var count func(c *coin)
+	count = func(c *coin) {
+		if c.symbol >= 0 {
+			lengths[c.symbol]++
+			return
+		}
+		count(c.left)
+		count(c.right)
+	}
+	for _, c := range list[:2*len(leaves)-2] {
+		count(c)
+	}
+	return lengths
+}
+
+func byteFrequencies(data []byte) [256]int {
+	var freqs [256]int
+	for _, b := range data {
+		freqs[b]++
+	}
+	return freqs
+}
+
+func longestCode(lengths [256]uint8) int {
+	longest := 0
+	for _, length := range lengths {
+		longest = max(longest, int(length))
+	}
+	return longest
+}
@@ -0,0 +1,196 @@
+package huffman
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fibonacci frequencies give the deepest possible Huffman tree
+func fibonacciData(symbols int) []byte {
+	var data []byte
+	a, b := 1, 1
+	for symbol := 0; symbol < symbols; symbol++ {
+		data = append(data, bytes.Repeat([]byte{byte(symbol)}, a)...)
+		a, b = b, a+b
+	}
+	return data
+}
+
+func codeCost(freqs []int, lengths []uint8) int {
+	cost := 0
+	for symbol, freq := range freqs {
+		cost += freq * int(lengths[symbol])
+	}
+	return cost
+}
+
+func TestPackageMerge(t *testing.T) {
+	type test_case struct {
+		description string
+		freqs       []int
+		limit       int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "limit above the huffman code lengths",
+			freqs:       []int{6, 1, 6, 2, 5},
+			limit:       15,
+		},
+		{
+			description: "skewed frequencies",
+			freqs:       []int{1, 1, 2, 3, 5, 8, 13},
+			limit:       3,
+		},
+		{
+			description: "skewed frequencies with unused symbols",
+			freqs:       []int{0, 1, 1, 0, 2, 3, 5, 0, 8},
+			limit:       4,
+		},
+		{
+			description: "limit equal to the minimum",
+			freqs:       []int{1, 100, 2, 3},
+			limit:       2,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			lengths := packageMerge(scenario.freqs, scenario.limit)
+
+			// brute force the cheapest complete code within the limit
+			best := -1
+			candidate := make([]uint8, len(scenario.freqs))
+			var search func(symbol int)
+			search = func(symbol int) {
+				if symbol == len(candidate) {
+					var kraft float64
+					for _, length := range candidate {
+						if length != 0 {
+							kraft += 1 / float64(uint64(1)<<length)
+						}
+					}
+					if kraft <= 1 && (best == -1 || codeCost(scenario.freqs, candidate) < best) {
+						best = codeCost(scenario.freqs, candidate)
+					}
+					return
+				}
+				if scenario.freqs[symbol] == 0 {
+					candidate[symbol] = 0
+					search(symbol + 1)
+					return
+				}
+				for length := 1; length <= scenario.limit; length++ {
+					candidate[symbol] = uint8(length)
+					search(symbol + 1)
+				}
+			}
+			search(0)
+
+			longest := 0
+			for _, length := range lengths {
+				longest = max(longest, int(length))
+			}
+			if codeCost(scenario.freqs, lengths) != best || longest > scenario.limit {
+				t.Fatalf(`Test %d Failed.
+				Got: lengths %v, cost %d,
+				Wanted: cost %d, lengths <= %d`, scenarioIdx, lengths, codeCost(scenario.freqs, lengths), best, scenario.limit)
+			}
+		})
+	}
+}
+
+func TestMaxCodeLength(t *testing.T) {
+	data := fibonacciData(25)
+
+	var encoded bytes.Buffer
+	e := Encoder{MaxCodeLength: 15}
+	if _, err := e.Encode(&encoded, data); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	decoded, err := Decode(encoded.Bytes())
+
+	lengths := e.Codes().Lengths()
+	longest := longestCode(lengths)
+	if err != nil || !bytes.Equal(decoded, data) || longest != 15 || checkCodeLengths(lengths[:]) != nil {
+		t.Fatalf(`Test Max Code Length Failed.
+		Got: longest code %d, err %v, equal data: %v,
+		Wanted: longest code 15, err <nil>, equal data: true`, longest, err, bytes.Equal(decoded, data))
+	}
+
+	if _, err := (&Encoder{MaxCodeLength: MinCodeLength - 1}).Encode(&encoded, data); err == nil {
+		t.Fatalf("max code length %d: got err <nil>, wanted err != <nil>", MinCodeLength-1)
+	}
+}
+
+func TestLimitedCodeLengths_ShouldFail(t *testing.T) {
+	var all [256]int
+	for b := range all {
+		all[b] = b + 1
+	}
+	var five [256]int
+	copy(five[:], []int{1, 1, 2, 3, 5})
+
+	type test_case struct {
+		description string
+		freqs       [256]int
+		limit       int
+		fails       bool
+	}
+
+	test_cases := []test_case{
+		{
+			description: "all bytes within 8 bits",
+			freqs:       all,
+			limit:       8,
+		},
+		{
+			description: "all bytes within 7 bits",
+			freqs:       all,
+			limit:       7,
+			fails:       true,
+		},
+		{
+			description: "5 symbols within 3 bits",
+			freqs:       five,
+			limit:       3,
+		},
+		{
+			description: "5 symbols within 2 bits",
+			freqs:       five,
+			limit:       2,
+			fails:       true,
+		},
+		{
+			description: "zero limit",
+			freqs:       five,
+			limit:       0,
+			fails:       true,
+		},
+		{
+			description: "negative limit",
+			freqs:       five,
+			limit:       -1,
+			fails:       true,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			lengths, err := LimitedCodeLengths(scenario.freqs, scenario.limit)
+
+			if (err != nil) != scenario.fails {
+				t.Fatalf(`Test %d Failed.
+				Got: err %v,
+				Wanted: failure %v`, scenarioIdx, err, scenario.fails)
+			}
+			if err == nil && (longestCode(lengths) > scenario.limit || checkCodeLengths(lengths[:]) != nil) {
+				t.Fatalf("Test %d Failed. invalid lengths %v", scenarioIdx, lengths)
+			}
+		})
+	}
+
+	if _, err := (&HuffmanCoder{MaxCodeLength: 2}).Encode(&bytes.Buffer{}, fibonacciData(5)); err == nil {
+		t.Fatalf("Test HuffmanCoder Failed. got err <nil>, wanted err != <nil>")
+	}
+}
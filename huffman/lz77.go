@@ -0,0 +1,377 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	// MaxLevel is the level of the slowest and strongest LZ77 match search.
+	MaxLevel int = 9
+
+	// MinWindow and MaxWindow bound how far back an LZ77 match can start.
+	MinWindow     int = 1 << 10
+	MaxWindow     int = 1 << 15
+	DefaultWindow int = MaxWindow
+)
+
+const (
+	minMatch int = 3
+	maxMatch int = 258
+
+	// matches of minMatch bytes further than this cost more than literals
+	tooFar int = 4096
+
+	// number of symbols of the literal/length alphabet: 256 literals, an
+	// unused end of block symbol and 29 length codes
+	literalLengthSymbols int = 286
+	distanceSymbols      int = 30
+
+	// longest code of the literal/length and distance alphabets
+	lzCodeLimit int = 15
+
+	hashBits int = 15
+)
+
+// base match length and number of extra bits of each length code, the
+// extra bits giving the offset from the base
+var (
+	lengthBase = [29]int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+		35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258,
+	}
+	lengthExtra = [29]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+		3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0,
+	}
+	distanceBase = [30]int{
+		1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+		257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577,
+	}
+	distanceExtra = [30]uint8{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+		7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13,
+	}
+)
+
+// lengthCodes gives the length code of each match length
+var lengthCodes = func() [maxMatch + 1]uint8 {
+	var codes [maxMatch + 1]uint8
+	for code := len(lengthBase) - 1; code >= 0; code-- {
+		for length := lengthBase[code]; length <= maxMatch && codes[length] == 0; length++ {
+			codes[length] = uint8(code)
+		}
+	}
+	return codes
+}()
+
+// distanceCode returns the distance code of a match distance.
+func distanceCode(distance int) int {
+	code := len(distanceBase) - 1
+	for distanceBase[code] > distance {
+		code--
+	}
+	return code
+}
+
+// searchLevel sets how hard the match finder looks for long matches.
+type searchLevel struct {
+	lazy  int // a match shorter than this is dropped if the next byte starts a longer one, 0 for none
+	nice  int // stop looking once a match is this long
+	chain int // number of earlier positions with the same hash tried
+}
+
+// search levels 1 to MaxLevel, the same trade-offs as zlib
+var searchLevels = [MaxLevel + 1]searchLevel{
+	1: {lazy: 0, nice: 8, chain: 4},
+	2: {lazy: 0, nice: 16, chain: 8},
+	3: {lazy: 0, nice: 32, chain: 32},
+	4: {lazy: 4, nice: 16, chain: 16},
+	5: {lazy: 16, nice: 32, chain: 32},
+	6: {lazy: 16, nice: 128, chain: 128},
+	7: {lazy: 32, nice: 128, chain: 256},
+	8: {lazy: 128, nice: maxMatch, chain: 1024},
+	9: {lazy: maxMatch, nice: maxMatch, chain: 4096},
+}
+
+// token is a literal byte, or a match of length bytes starting distance
+// bytes back.
+type token struct {
+	length   uint16 // 0 for a literal
+	distance uint16 // the literal byte for a literal
+}
+
+// matchFinder finds the longest earlier occurence of the bytes at each
+// position with hash chains: head holds the last position of each hash of 3
+// bytes, and prev links every position of the window to the previous one
+// with the same hash.
+type matchFinder struct {
+	data   []byte
+	window int
+	level  searchLevel
+	head   []int32
+	prev   []int32
+}
+
+func newMatchFinder(data []byte, window int, level int) *matchFinder {
+	m := &matchFinder{
+		data:   data,
+		window: window,
+		level:  searchLevels[level],
+		head:   make([]int32, 1<<hashBits),
+		prev:   make([]int32, window),
+	}
+	for i := range m.head {
+		m.head[i] = -1
+	}
+	return m
+}
+
+func (m *matchFinder) hash(i int) uint32 {
+	v := uint32(m.data[i])<<16 | uint32(m.data[i+1])<<8 | uint32(m.data[i+2])
+	return v * 2654435761 >> (32 - hashBits)
+}
+
+// insert adds position i to the chains, i being followed by at least
+// minMatch bytes.
+func (m *matchFinder) insert(i int) {
+	h := m.hash(i)
+	m.prev[i&(m.window-1)] = m.head[h]
+	m.head[h] = int32(i)
+}
+
+// longest returns the longest match for position i among the positions
+// already inserted, a length under minMatch if there's none.
+func (m *matchFinder) longest(i int) (int, int) {
+	limit := min(maxMatch, len(m.data)-i)
+	best, best_distance := 0, 0
+	candidate := int(m.head[m.hash(i)])
+	for chain := m.level.chain; candidate >= 0 && i-candidate <= m.window && chain > 0; chain-- {
+		// check the byte that would make the match longer first
+		if m.data[candidate+best] == m.data[i+best] || best == 0 {
+			length := 0
+			for length < limit && m.data[candidate+length] == m.data[i+length] {
+				length++
+			}
+			if length > best {
+				best, best_distance = length, i-candidate
+				if length >= m.level.nice || length == limit {
+					break
+				}
+			}
+		}
+		candidate = int(m.prev[candidate&(m.window-1)])
+	}
+	if best == minMatch && best_distance > tooFar {
+		return 0, 0
+	}
+	return best, best_distance
+}
+
+// tokens splits data into literals and matches.
+func (m *matchFinder) tokens() []token {
+	data := m.data
+	tokens := make([]token, 0, len(data)/2)
+	for i := 0; i < len(data); {
+		if i+minMatch > len(data) {
+			tokens = append(tokens, token{distance: uint16(data[i])})
+			i++
+			continue
+		}
+
+		length, distance := m.longest(i)
+		m.insert(i)
+		if length >= minMatch && length < m.level.lazy && i+1+minMatch <= len(data) {
+			// a longer match at the next byte is worth a literal
+			if next_length, _ := m.longest(i + 1); next_length > length {
+				length = 0
+			}
+		}
+
+		if length < minMatch {
+			tokens = append(tokens, token{distance: uint16(data[i])})
+			i++
+			continue
+		}
+		tokens = append(tokens, token{length: uint16(length), distance: uint16(distance)})
+		for j := i + 1; j < i+length && j+minMatch <= len(data); j++ {
+			m.insert(j)
+		}
+		i += length
+	}
+	return tokens
+}
+
+// encodeLZBlock writes data as a single block of LZ77 literals and matches
+// coded with a literal/length table and a distance table, without its size
+// prefix. Matches don't reach before the start of the block.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeLZBlock(w io.Writer, data []byte) (int, error) {
+	tokens := newMatchFinder(data, e.window(), e.Level).tokens()
+
+	literal_freqs := make([]int, literalLengthSymbols)
+	distance_freqs := make([]int, distanceSymbols)
+	for _, t := range tokens {
+		if t.length == 0 {
+			literal_freqs[t.distance]++
+			continue
+		}
+		literal_freqs[257+int(lengthCodes[t.length])]++
+		distance_freqs[distanceCode(int(t.distance))]++
+	}
+	literal_lengths := packageMerge(literal_freqs, lzCodeLimit)
+	distance_lengths := packageMerge(distance_freqs, lzCodeLimit)
+	literal_codes := canonicalCodes(literal_lengths)
+	distance_codes := canonicalCodes(distance_lengths)
+
+	bw := BitWriter{io_writer: w}
+	for _, b := range binary.AppendUvarint(nil, uint64(len(tokens))) {
+		bw.WriteByte(b)
+	}
+	bw.writeLengths(literal_lengths)
+	bw.writeLengths(distance_lengths)
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			bw.WriteCode(literal_codes[t.distance])
+			continue
+		}
+		code := lengthCodes[t.length]
+		bw.WriteCode(literal_codes[257+int(code)])
+		bw.WriteBits(uint64(int(t.length)-lengthBase[code]), lengthExtra[code])
+
+		distance := int(t.distance)
+		code = uint8(distanceCode(distance))
+		bw.WriteCode(distance_codes[code])
+		bw.WriteBits(uint64(distance-distanceBase[code]), distanceExtra[code])
+	}
+	e.tree = nil
+	e.codes = CodeTable{}
+
+	return bw.FlushPadded()
+}
+
+func (e *Encoder) window() int {
+	if e.Window == 0 {
+		return DefaultWindow
+	}
+	return e.Window
+}
+
+var errInvalidMatch = errors.New("huffman: invalid match")
+
+// lzDecoder holds the tables of an LZ77 or DEFLATE block and the bytes
+// matches copy from.
+type lzDecoder struct {
+	literals  *decodeTable // literal/length decode table
+	distances *decodeTable // distance decode table, nil without matches
+	history   []byte       // bytes decoded from the LZ77 block, or the last window of a DEFLATE stream
+	match     int          // bytes of the current match left to copy
+	distance  int          // distance of the current match
+	deflate   bool         // extra bits are read least significant first
+}
+
+// lzBlock decodes an LZ77 block.
+type lzBlock struct {
+	lzDecoder
+	count uint64 // number of tokens left in the block
+}
+
+// readLZTables reads the literal/length and distance tables of an LZ77
+// block of count tokens.
+func (z *Reader) readLZTables(count uint64) (*lzBlock, error) {
+	literal_lengths := make([]uint8, literalLengthSymbols)
+	if err := z.br.readLengths(literal_lengths); err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	distance_lengths := make([]uint8, distanceSymbols)
+	if err := z.br.readLengths(distance_lengths); err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+
+	d := &lzBlock{count: count}
+	var err error
+	if d.literals, err = newCanonicalDecodeTable(literal_lengths); err != nil {
+		return nil, err
+	}
+	if slices.Max(distance_lengths) != 0 {
+		if d.distances, err = newCanonicalDecodeTable(distance_lengths); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// decode returns the next byte of the block, a literal or the next byte of
+// a match. Returns io.EOF at the end of the block.
+func (d *lzBlock) decode(br *BitReader) (byte, error) {
+	if d.match == 0 {
+		if d.count == 0 {
+			return 0, io.EOF
+		}
+		if err := d.readToken(br); err != nil {
+			return 0, err
+		}
+	}
+	return d.next(), nil
+}
+
+// readToken decodes a literal, appended to the history, or a match, whose
+// length and distance are kept to copy it byte by byte.
+func (d *lzBlock) readToken(br *BitReader) error {
+	symbol, err := d.literals.decodeSymbol(br)
+	if err != nil {
+		return symbolError(err, d.count)
+	}
+	d.count--
+	if symbol < 256 {
+		d.history = append(d.history, byte(symbol))
+		return nil
+	}
+	return d.readMatch(br, symbol)
+}
+
+// next returns the last literal decoded, or copies the next byte of the
+// current match.
+func (d *lzDecoder) next() byte {
+	if d.match > 0 {
+		d.match--
+		d.history = append(d.history, d.history[len(d.history)-d.distance])
+	}
+	return d.history[len(d.history)-1]
+}
+
+// readMatch reads the length extra bits and the distance following length
+// symbol, and keeps them to copy the match byte by byte.
+func (d *lzDecoder) readMatch(br *BitReader, symbol int) error {
+	code := symbol - 257
+	if code < 0 || code >= len(lengthBase) || d.distances == nil {
+		return fmt.Errorf("%w: length symbol %d", errInvalidMatch, symbol)
+	}
+	extra, err := readExtraBits(br, lengthExtra[code], d.deflate)
+	if err != nil {
+		return unexpected(err)
+	}
+	length := lengthBase[code] + int(extra)
+
+	code, err = d.distances.decodeSymbol(br)
+	if err != nil {
+		return unexpected(err)
+	}
+	if code >= len(distanceBase) {
+		return fmt.Errorf("%w: distance symbol %d", errInvalidMatch, code)
+	}
+	if extra, err = readExtraBits(br, distanceExtra[code], d.deflate); err != nil {
+		return unexpected(err)
+	}
+	distance := distanceBase[code] + int(extra)
+	if distance > len(d.history) || length > maxMatch {
+		return fmt.Errorf("%w: distance %d past the start of the data", errInvalidMatch, distance)
+	}
+
+	d.match, d.distance = length, distance
+	return nil
+}
@@ -0,0 +1,221 @@
+package huffman
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// logData returns log lines, repetitive the way our log files are.
+func logData(size int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	levels := []string{"INFO", "INFO", "INFO", "WARN", "ERROR"}
+	paths := []string{"/api/users", "/api/orders", "/health", "/api/orders/items", "/login"}
+	var data bytes.Buffer
+	for data.Len() < size {
+		fmt.Fprintf(&data, "2024-03-%02d 12:%02d:%02d %s server: request id=%d path=%s status=%d duration=%dms\n",
+			1+rng.Intn(28), rng.Intn(60), rng.Intn(60), levels[rng.Intn(len(levels))],
+			rng.Intn(100000), paths[rng.Intn(len(paths))], 200+100*rng.Intn(3), rng.Intn(500))
+	}
+	return data.Bytes()[:size]
+}
+
+// untokenize rebuilds the data of tokens.
+func untokenize(tokens []token) []byte {
+	var data []byte
+	for _, t := range tokens {
+		if t.length == 0 {
+			data = append(data, byte(t.distance))
+			continue
+		}
+		for i := 0; i < int(t.length); i++ {
+			data = append(data, data[len(data)-int(t.distance)])
+		}
+	}
+	return data
+}
+
+func TestMatchFinder(t *testing.T) {
+	data := append(logData(100_000, 20), bytes.Repeat([]byte("a"), 1000)...)
+
+	for level := 1; level <= MaxLevel; level++ {
+		for _, window := range []int{MinWindow, MaxWindow} {
+			tokens := newMatchFinder(data, window, level).tokens()
+
+			for _, token := range tokens {
+				if token.length != 0 && (int(token.length) < minMatch || int(token.length) > maxMatch || int(token.distance) > window) {
+					t.Fatalf("Test level %d window %d Failed. invalid match %+v", level, window, token)
+				}
+			}
+			if !bytes.Equal(untokenize(tokens), data) {
+				t.Fatalf("Test level %d window %d Failed. tokens don't rebuild the data", level, window)
+			}
+		}
+	}
+}
+
+func TestLZRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		level       int
+		window      int
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "shorter than a match",
+			data:        []byte("ab"),
+			level:       6,
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 1000),
+			level:       1,
+		},
+		{
+			description: "no match",
+			data:        []byte("huffman coding"),
+			level:       9,
+		},
+		{
+			description: "log lines",
+			data:        logData(200_000, 21),
+			level:       6,
+		},
+		{
+			description: "log lines, small window",
+			data:        logData(200_000, 22),
+			level:       9,
+			window:      MinWindow,
+		},
+		{
+			description: "binary data",
+			data:        randomData(100_000, 256, 23),
+			level:       4,
+		},
+		{
+			description: "several blocks",
+			data:        logData(3*MinBlockSize+17, 24),
+			level:       2,
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := Encoder{BlockSize: scenario.block_size, Level: scenario.level, Window: scenario.window}
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Flags&FlagLZ77 == 0 {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, header %+v,
+				Wanted: %d bytes, <nil>, LZ77 header`, scenarioIdx, len(decoded), err, zr.Header(), len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestLZGain(t *testing.T) {
+	data := logData(1<<20, 25)
+	huffman_only, _ := (&Encoder{}).Encode(io.Discard, data)
+
+	prev := huffman_only
+	for _, level := range []int{1, 6, MaxLevel} {
+		lz, _ := (&Encoder{Level: level}).Encode(io.Discard, data)
+		t.Logf("level %d: %d bytes, %.02f%% of Huffman only", level, lz, 100*float64(lz)/float64(huffman_only))
+
+		if lz > prev {
+			t.Fatalf(`Test Level %d Failed.
+			Got: %d bytes,
+			Wanted: at most %d bytes`, level, lz, prev)
+		}
+		prev = lz
+	}
+	if 2*prev > huffman_only {
+		t.Fatalf(`Test LZ Gain Failed.
+		Got: %d bytes,
+		Wanted: less than half of Huffman only %d bytes`, prev, huffman_only)
+	}
+}
+
+func TestLZ_ShouldFail(t *testing.T) {
+	for _, e := range []Encoder{
+		{Level: -1},
+		{Level: MaxLevel + 1},
+		{Level: 1, Window: MinWindow - 1},
+		{Level: 1, Window: MaxWindow + 1},
+		{Level: 1, Window: 3 * MinWindow},
+		{Level: 1, Order1: true},
+		{Level: 1, Adaptive: true},
+		{Level: 1, Tables: 2},
+	} {
+		if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with %+v: got err <nil>, wanted err != <nil>", e)
+		}
+	}
+
+	// a match at the start of the block has nothing to copy
+	var block bytes.Buffer
+	bw := NewBitWriter(&block)
+	bw.WriteByte(1)
+	literal_lengths := make([]uint8, literalLengthSymbols)
+	literal_lengths['a'], literal_lengths[257] = 1, 1
+	distance_lengths := make([]uint8, distanceSymbols)
+	distance_lengths[0] = 1
+	bw.writeLengths(literal_lengths)
+	bw.writeLengths(distance_lengths)
+	bw.WriteBits(0b10, 2)
+	bw.FlushPadded()
+
+	var encoded bytes.Buffer
+	writeHeader(&encoded, Header{Version: FormatVersion, Flags: FlagLZ77})
+	block_size_bytes, _ := intToBytes(uint32(block.Len()))
+	encoded.Write(block_size_bytes)
+	encoded.Write(block.Bytes())
+	encoded.Write([]byte{0, 0, 0, 0})
+
+	_, err := io.ReadAll(NewReader(&encoded))
+	if err == nil {
+		t.Fatalf(`Test Match Before Block Failed.
+		Got: err <nil>,
+		Wanted: err != <nil>`)
+	}
+}
+
+func BenchmarkEncodeLZ(b *testing.B) {
+	data := logData(4<<20, 26)
+	huffman_only, _ := (&Encoder{}).Encode(io.Discard, data)
+	for _, level := range []int{1, 4, 6, MaxLevel} {
+		b.Run(fmt.Sprintf("level %d", level), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			size := 0
+			for i := 0; i < b.N; i++ {
+				size, _ = (&Encoder{Level: level}).Encode(io.Discard, data)
+			}
+			b.ReportMetric(float64(size)/float64(huffman_only), "size/huffman")
+		})
+	}
+}
+
+func BenchmarkDecodeLZ(b *testing.B) {
+	data := logData(4<<20, 27)
+	var encoded bytes.Buffer
+	(&Encoder{Level: 6}).Encode(&encoded, data)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Copy(io.Discard, NewReader(bytes.NewReader(encoded.Bytes())))
+	}
+}
@@ -0,0 +1,227 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MaxTables is the largest number of code tables of a block.
+const MaxTables int = 6
+
+// number of symbols coded with the same table, each segment of a block
+// choosing its own
+const segmentSize int = 50
+
+// number of times the tables are rebuilt from the segments choosing them
+const tableIterations int = 4
+
+// encodeMultiTableBlock writes data as a single block coded with several
+// tables, the way bzip2 does, without its size prefix.
+//
+// Tables start from a partition of the bytes of the block into ranges of
+// about the same frequency, each table making its range cheap. Each segment
+// of segmentSize bytes then picks the table coding it in the fewest bits,
+// and each table is rebuilt from the bytes of the segments that picked it,
+// tableIterations times. The choice of each segment, its selector, is
+// stored move-to-front coded: the position of the table in a list of the
+// tables most recently used first, written in unary.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeMultiTableBlock(w io.Writer, data []byte) (int, error) {
+	freqs := byteFrequencies(data)
+	segments := (len(data) + segmentSize - 1) / segmentSize
+	tables := make([][256]uint8, min(e.Tables, maxUsefulTables(len(data))))
+	initialTables(tables, freqs, len(data))
+
+	selectors := make([]uint8, segments)
+	for iteration := 0; iteration < tableIterations; iteration++ {
+		table_freqs := make([][256]int, len(tables))
+		for segment := range selectors {
+			symbols := data[segment*segmentSize : min((segment+1)*segmentSize, len(data))]
+			selectors[segment] = cheapestTable(tables, symbols)
+			for _, b := range symbols {
+				table_freqs[selectors[segment]][b]++
+			}
+		}
+
+		for t := range tables {
+			// every byte of the block keeps a code in every table, so that
+			// any segment can pick any table
+			for b, freq := range freqs {
+				if freq > 0 && table_freqs[t][b] == 0 {
+					table_freqs[t][b] = 1
+				}
+			}
+			tables[t] = e.codeLengths(table_freqs[t])
+		}
+	}
+	for segment := range selectors {
+		symbols := data[segment*segmentSize : min((segment+1)*segmentSize, len(data))]
+		selectors[segment] = cheapestTable(tables, symbols)
+	}
+
+	bw := BitWriter{io_writer: w}
+	for _, b := range binary.AppendUvarint(nil, uint64(len(data))) {
+		bw.WriteByte(b)
+	}
+	bw.WriteBits(uint64(len(tables)), 3)
+	codes := make([]CodeTable, len(tables))
+	for t, lengths := range tables {
+		bw.WriteCodeLengths(lengths)
+		codes[t] = NewCanonicalCodeTable(lengths)
+	}
+
+	mtf := []uint8{0, 1, 2, 3, 4, 5}[:len(tables)]
+	for _, selector := range selectors {
+		position := 0
+		for mtf[position] != selector {
+			position++
+		}
+		copy(mtf[1:position+1], mtf[:position])
+		mtf[0] = selector
+		for i := 0; i < position; i++ {
+			bw.WriteBit(1)
+		}
+		bw.WriteBit(0)
+	}
+
+	for i, b := range data {
+		bw.WriteCode(codes[selectors[i/segmentSize]][b])
+	}
+	e.tree = nil
+	e.codes = codes[selectors[0]]
+
+	return bw.FlushPadded()
+}
+
+// maxUsefulTables returns how many tables a block of size bytes can afford:
+// on small blocks, storing more tables costs more than they save. These are
+// the thresholds of bzip2 with one table less each, as tables cost more to
+// store here.
+func maxUsefulTables(size int) int {
+	switch {
+	case size < 200:
+		return 1
+	case size < 600:
+		return 2
+	case size < 1200:
+		return 3
+	case size < 2400:
+		return 4
+	case size < 4800:
+		return 5
+	}
+	return MaxTables
+}
+
+// initialTables splits the bytes into len(tables) ranges of about the same
+// total frequency, each table giving short codes to its range only.
+func initialTables(tables [][256]uint8, freqs [256]int, total int) {
+	remaining := total
+	start := 0
+	for part := len(tables); part > 0; part-- {
+		target := remaining / part
+		end := start - 1
+		range_freq := 0
+		for range_freq < target && end < 255 {
+			end++
+			range_freq += freqs[end]
+		}
+		// like bzip2, alternately leave the last byte to the next range
+		if end > start && part != len(tables) && part != 1 && (len(tables)-part)%2 == 1 {
+			range_freq -= freqs[end]
+			end--
+		}
+
+		for b := range tables[part-1] {
+			if b >= start && b <= end {
+				tables[part-1][b] = 1
+			} else {
+				tables[part-1][b] = 15
+			}
+		}
+		start = end + 1
+		remaining -= range_freq
+	}
+}
+
+// cheapestTable returns the table coding symbols in the fewest bits, the
+// first one on ties.
+func cheapestTable(tables [][256]uint8, symbols []byte) uint8 {
+	best, best_cost := 0, -1
+	for t := range tables {
+		cost := 0
+		for _, b := range symbols {
+			cost += int(tables[t][b])
+		}
+		if best_cost < 0 || cost < best_cost {
+			best, best_cost = t, cost
+		}
+	}
+	return uint8(best)
+}
+
+// multiTableBlock decodes a block coded with several tables.
+type multiTableBlock struct {
+	tables    []*decodeTable // decode table of each table
+	selectors []uint8        // table of each segment of the block
+	index     int            // index of the next symbol in the block
+	count     uint64         // number of symbols left in the block
+}
+
+// readMultiTables reads the code tables and the selectors of a block of
+// count symbols coded with several tables.
+func (z *Reader) readMultiTables(count uint64) (*multiTableBlock, error) {
+	table_count, err := z.br.ReadBits(3)
+	if err != nil {
+		return nil, fmt.Errorf("error reading table count %w", unexpected(err))
+	}
+	if table_count == 0 || int(table_count) > MaxTables {
+		return nil, fmt.Errorf("huffman: invalid table count %d", table_count)
+	}
+
+	d := &multiTableBlock{tables: make([]*decodeTable, table_count), count: count}
+	for t := range d.tables {
+		if d.tables[t], err = z.readDecodeTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	mtf := []uint8{0, 1, 2, 3, 4, 5}[:table_count]
+	d.selectors = make([]uint8, (count+uint64(segmentSize)-1)/uint64(segmentSize))
+	for segment := range d.selectors {
+		position := 0
+		for {
+			bit, err := z.br.ReadBit()
+			if err != nil {
+				return nil, fmt.Errorf("error reading selectors %w", unexpected(err))
+			}
+			if bit == 0 {
+				break
+			}
+			if position++; position == len(mtf) {
+				return nil, fmt.Errorf("huffman: invalid selector")
+			}
+		}
+		selector := mtf[position]
+		copy(mtf[1:position+1], mtf[:position])
+		mtf[0] = selector
+		d.selectors[segment] = selector
+	}
+	return d, nil
+}
+
+// decode returns the next byte of the block, coded with the table of its
+// segment. Returns io.EOF at the end of the block.
+func (d *multiTableBlock) decode(br *BitReader) (byte, error) {
+	if d.count == 0 {
+		return 0, io.EOF
+	}
+	b, err := d.tables[d.selectors[d.index/segmentSize]].decode(br)
+	if err != nil {
+		return 0, symbolError(err, d.count)
+	}
+	d.index++
+	d.count--
+	return b, nil
+}
@@ -0,0 +1,164 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// mixedData returns data alternating between text and binary content every
+// few hundred bytes, which a single table per block codes poorly.
+func mixedData(size int, seed int64) []byte {
+	text := sourceText()
+	random := randomData(size, 256, seed)
+	data := make([]byte, 0, size)
+	for i := 0; len(data) < size; i++ {
+		chunk := min(300+i%7*100, size-len(data))
+		if i%2 == 0 {
+			start := i * 1000 % (len(text) - chunk)
+			data = append(data, text[start:start+chunk]...)
+		} else {
+			data = append(data, random[len(data):len(data)+chunk]...)
+		}
+	}
+	return data
+}
+
+func TestMultiTableRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		tables      int
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "fewer segments than tables",
+			data:        []byte("huffman coding and decoding in golang"),
+			tables:      MaxTables,
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 1000),
+			tables:      3,
+		},
+		{
+			description: "last segment partial",
+			data:        mixedData(10_017, 14),
+			tables:      2,
+		},
+		{
+			description: "mixed content",
+			data:        mixedData(200_000, 15),
+			tables:      MaxTables,
+		},
+		{
+			description: "several blocks",
+			data:        mixedData(3*MinBlockSize+17, 16),
+			tables:      4,
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := Encoder{BlockSize: scenario.block_size, Tables: scenario.tables}
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Header().Flags&FlagMultiTable == 0 {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, header %+v,
+				Wanted: %d bytes, <nil>, multi-table header`, scenarioIdx, len(decoded), err, zr.Header(), len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestMultiTableGain(t *testing.T) {
+	data := mixedData(1<<20, 17)
+	single, _ := (&Encoder{}).Encode(io.Discard, data)
+	multi, _ := (&Encoder{Tables: MaxTables}).Encode(io.Discard, data)
+	t.Logf("%d bytes: 1 table %d bytes, %d tables %d bytes (%.02f%%)", len(data), single, MaxTables, multi, 100*float64(multi)/float64(single))
+
+	if multi >= single {
+		t.Fatalf(`Test Multi Table Gain Failed.
+		Got: %d tables %d bytes,
+		Wanted: less than 1 table %d bytes`, MaxTables, multi, single)
+	}
+}
+
+func TestMultiTable_ShouldFail(t *testing.T) {
+	data := mixedData(1000, 18)
+	var encoded bytes.Buffer
+	(&Encoder{Tables: 2}).Encode(&encoded, data)
+
+	// the table count follows the symbol count, on the 3 highest bits
+	count_idx := headerSize + 4 + len(binary.AppendUvarint(nil, uint64(len(data))))
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte)
+	}
+
+	test_cases := []test_case{
+		{
+			description: "no table",
+			tamper:      func(encoded []byte) { encoded[count_idx] &= 0b0001_1111 },
+		},
+		{
+			description: "too many tables",
+			tamper:      func(encoded []byte) { encoded[count_idx] |= 0b1110_0000 },
+		},
+		{
+			description: "ones over the tables and selectors",
+			tamper:      func(encoded []byte) { copy(encoded[count_idx+60:], bytes.Repeat([]byte{0xff}, 20)) },
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := append([]byte{}, encoded.Bytes()...)
+			scenario.tamper(tampered)
+
+			_, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+
+	for _, tables := range []int{-1, MaxTables + 1} {
+		if _, err := (&Encoder{Tables: tables}).Encode(io.Discard, data); err == nil {
+			t.Fatalf("Encode with %d tables: got err <nil>, wanted err != <nil>", tables)
+		}
+	}
+}
+
+func BenchmarkEncodeMultiTable(b *testing.B) {
+	corpora := append(benchmarkCorpora(), benchmarkCorpus{"mixed", mixedData(4<<20, 19)})
+	for _, corpus := range corpora {
+		b.Run(corpus.name, func(b *testing.B) {
+			single, _ := (&Encoder{}).Encode(io.Discard, corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			multi := 0
+			for i := 0; i < b.N; i++ {
+				multi, _ = (&Encoder{Tables: MaxTables}).Encode(io.Discard, corpus.data)
+			}
+			b.ReportMetric(float64(multi)/float64(single), "size/single")
+		})
+	}
+}
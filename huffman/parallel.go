@@ -0,0 +1,118 @@
+package huffman
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// blockJob is a block encoded or decoded on a goroutine of its own.
+type blockJob struct {
+	in    []byte // input of the block
+	out   []byte // encoding or decoding of in
+	tree  *Node  // tree of the block, when encoding
+	codes CodeTable
+	err   error
+	done  chan struct{} // closed once out is ready
+}
+
+func (e *Encoder) concurrency() int {
+	return max(e.Concurrency, 1)
+}
+
+// queueBlock starts encoding the pending input on a goroutine, then writes
+// out the oldest block being encoded if Concurrency blocks are.
+func (z *Writer) queueBlock() error {
+	job := &blockJob{in: z.data, done: make(chan struct{})}
+	// each block has an Encoder of its own for its tree and codes
+	enc := *z.enc
+	go func() {
+		defer close(job.done)
+		var encoded bytes.Buffer
+		_, job.err = enc.encodeBlock(&encoded, job.in)
+		job.out, job.tree, job.codes = encoded.Bytes(), enc.tree, enc.codes
+	}()
+	z.data = nil
+	z.pending = append(z.pending, job)
+
+	if len(z.pending) < z.enc.concurrency() {
+		return nil
+	}
+	return z.writePending(1)
+}
+
+// writePending waits for the n oldest blocks being encoded and writes them
+// out in order, recycling their input for the next block.
+func (z *Writer) writePending(n int) error {
+	for ; n > 0; n-- {
+		job := z.pending[0]
+		<-job.done
+		z.pending = z.pending[1:]
+		if z.data == nil {
+			z.data = job.in[:0]
+		}
+		if z.err = job.err; z.err != nil {
+			return z.err
+		}
+		z.enc.tree, z.enc.codes = job.tree, job.codes
+
+		block_size_bytes, _ := intToBytes(uint32(len(job.out)))
+		if _, z.err = z.w.Write(block_size_bytes); z.err != nil {
+			return z.err
+		}
+		if _, z.err = z.w.Write(job.out); z.err != nil {
+			return z.err
+		}
+	}
+	return nil
+}
+
+// nextDecodedBlock reads blocks ahead until workers of them are being
+// decoded, each on a goroutine, then waits for the oldest one.
+// Returns io.EOF at the end of the stream.
+func (z *Reader) nextDecodedBlock() error {
+	for !z.last && len(z.pending) < z.workers {
+		block_size, err := z.readBlockSize()
+		if err == io.EOF {
+			z.last = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		body := make([]byte, block_size)
+		if _, err := io.ReadFull(z.r, body); err != nil {
+			return fmt.Errorf("error reading block %w", unexpected(err))
+		}
+
+		job := &blockJob{in: body, done: make(chan struct{})}
+		block := &Reader{header: z.header, transforms: z.transforms, coder: z.coder, alphabet: z.alphabet}
+		go func() {
+			defer close(job.done)
+			job.out, job.err = block.decodeBlock(job.in)
+		}()
+		z.pending = append(z.pending, job)
+	}
+
+	if len(z.pending) == 0 {
+		return io.EOF
+	}
+	job := z.pending[0]
+	<-job.done
+	z.pending = z.pending[1:]
+	z.decoded = job.out
+	return job.err
+}
+
+// decodeBlock decodes a whole block, body being its encoding without its
+// size.
+func (z *Reader) decodeBlock(body []byte) ([]byte, error) {
+	if err := z.startBlock(bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+	decoded, err := z.readCodedBlock()
+	if err != nil || z.transforms == nil {
+		return decoded, err
+	}
+	return invertTransforms(z.transforms, decoded)
+}
@@ -0,0 +1,261 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParallelRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single block",
+			data:        []byte("abracadabra"),
+		},
+		{
+			description: "several blocks",
+			data:        randomData(7*MinBlockSize+17, 64, 43),
+			encoder:     Encoder{BlockSize: MinBlockSize},
+		},
+		{
+			description: "order-1",
+			data:        markovData(5*MinBlockSize, 44),
+			encoder:     Encoder{BlockSize: MinBlockSize, Order1: true},
+		},
+		{
+			description: "multi-table",
+			data:        mixedData(5*MinBlockSize, 45),
+			encoder:     Encoder{BlockSize: MinBlockSize, Tables: 4},
+		},
+		{
+			description: "LZ77",
+			data:        logData(5*MinBlockSize, 46),
+			encoder:     Encoder{BlockSize: MinBlockSize, Level: 6},
+		},
+		{
+			description: "transforms",
+			data:        sourceText(),
+			encoder:     Encoder{BlockSize: MinBlockSize, Transforms: bzip2Transforms},
+		},
+		{
+			description: "rANS",
+			data:        sourceText(),
+			encoder:     Encoder{BlockSize: MinBlockSize, Coder: CoderRANS},
+		},
+		{
+			description: "words",
+			data:        wordData(5*MinBlockSize, 47),
+			encoder:     Encoder{BlockSize: MinBlockSize, Alphabet: AlphabetWords},
+		},
+		{
+			description: "adaptive",
+			data:        sourceText()[:MinBlockSize],
+			encoder:     Encoder{Adaptive: true},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var sequential bytes.Buffer
+			e := scenario.encoder
+			if _, err := e.Encode(&sequential, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			for _, concurrency := range []int{2, 3, 8} {
+				var encoded bytes.Buffer
+				e := scenario.encoder
+				e.Concurrency = concurrency
+				if _, err := e.Encode(&encoded, scenario.data); err != nil {
+					t.Fatalf("Test %d Failed. concurrency %d encode error: %v", scenarioIdx, concurrency, err)
+				}
+				if !bytes.Equal(encoded.Bytes(), sequential.Bytes()) {
+					t.Fatalf(`Test %d Failed. concurrency %d
+					Got: %d bytes,
+					Wanted: the %d bytes encoded sequentially`, scenarioIdx, concurrency, encoded.Len(), sequential.Len())
+				}
+
+				d := Decoder{Concurrency: concurrency}
+				decoded, err := io.ReadAll(d.NewReader(iotest.HalfReader(&encoded)))
+
+				if err != nil || !bytes.Equal(decoded, scenario.data) {
+					t.Fatalf(`Test %d Failed. concurrency %d
+					Got: %d bytes, %v,
+					Wanted: %d bytes, <nil>`, scenarioIdx, concurrency, len(decoded), err, len(scenario.data))
+				}
+			}
+		})
+	}
+}
+
+func TestParallelFlush(t *testing.T) {
+	data := randomData(3*MinBlockSize, 64, 48)
+	var encoded bytes.Buffer
+	zw := (&Encoder{BlockSize: MinBlockSize, Concurrency: 4}).NewWriter(&encoded)
+	zw.Write(data[:2*MinBlockSize+1])
+
+	if err := zw.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	// every block written so far decodes, without the end of the stream
+	decoded, err := io.ReadAll(NewReader(bytes.NewReader(encoded.Bytes())))
+	if len(decoded) != 2*MinBlockSize+1 || !bytes.Equal(decoded, data[:len(decoded)]) {
+		t.Fatalf(`Test Flush Failed.
+		Got: %d bytes, %v,
+		Wanted: %d bytes`, len(decoded), err, 2*MinBlockSize+1)
+	}
+
+	zw.Write(data[2*MinBlockSize+1:])
+	zw.Close()
+	decoded, err = io.ReadAll(NewReader(&encoded))
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf(`Test Close Failed.
+		Got: %d bytes, %v,
+		Wanted: %d bytes, <nil>`, len(decoded), err, len(data))
+	}
+}
+
+func TestParallel_ShouldFail(t *testing.T) {
+	if _, err := (&Encoder{Concurrency: -1}).Encode(io.Discard, []byte("abc")); err == nil {
+		t.Fatalf("Encode with concurrency -1: got err <nil>, wanted err != <nil>")
+	}
+
+	data := randomData(4*MinBlockSize, 64, 49)
+	var encoded bytes.Buffer
+	(&Encoder{BlockSize: MinBlockSize}).Encode(&encoded, data)
+
+	// offset of the body of the second block
+	second_block_idx := headerSize + 4 + int(binary.BigEndian.Uint32(encoded.Bytes()[headerSize:])) + 4
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte) []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "flipped code bits in the second block",
+			tamper: func(encoded []byte) []byte {
+				encoded[second_block_idx+1000] ^= 0x55
+				return encoded
+			},
+		},
+		{
+			description: "invalid second block",
+			tamper: func(encoded []byte) []byte {
+				encoded[second_block_idx] = 0
+				return encoded
+			},
+		},
+		{
+			description: "truncated stream",
+			tamper: func(encoded []byte) []byte {
+				return encoded[:second_block_idx+100]
+			},
+		},
+		{
+			description: "checksum",
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-1] ^= 1
+				return encoded
+			},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := scenario.tamper(append([]byte{}, encoded.Bytes()...))
+
+			d := Decoder{Concurrency: 4}
+			decoded, err := io.ReadAll(d.NewReader(bytes.NewReader(tampered)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, err %v,
+				Wanted: err != <nil>`, scenarioIdx, len(decoded), err)
+			}
+		})
+	}
+}
+
+// size of the input of the parallel benchmarks, streamed so that it's
+// never held in memory
+const parallelBenchmarkSize int64 = 2 << 30
+
+// repeatReader reads data over and over, size bytes in all.
+type repeatReader struct {
+	data []byte
+	size int64
+	read int64
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.read == r.size {
+		return 0, io.EOF
+	}
+	offset := int(r.read % int64(len(r.data)))
+	n := copy(p[:min(int64(len(p)), r.size-r.read)], r.data[offset:])
+	r.read += int64(n)
+	return n, nil
+}
+
+// repeatedStream returns the encoding of block repeated count times, built
+// from the encoding of a single one with a trailer for the whole stream.
+func repeatedStream(e Encoder, block []byte, count int) io.Reader {
+	var encoded bytes.Buffer
+	e.Encode(&encoded, block)
+	frame := encoded.Bytes()[headerSize : encoded.Len()-4-trailerSize]
+
+	checksum := uint32(0)
+	for i := 0; i < count; i++ {
+		checksum = crc32.Update(checksum, crc32.IEEETable, block)
+	}
+	end := binary.BigEndian.AppendUint32(nil, 0)
+	end = binary.BigEndian.AppendUint64(end, uint64(count*len(block)))
+	end = binary.BigEndian.AppendUint32(end, checksum)
+
+	return io.MultiReader(
+		bytes.NewReader(encoded.Bytes()[:headerSize]),
+		&repeatReader{data: frame, size: int64(count * len(frame))},
+		bytes.NewReader(end),
+	)
+}
+
+func BenchmarkParallel(b *testing.B) {
+	if testing.Short() {
+		b.Skip("streams several GB")
+	}
+	block := mixedData(DefaultBlockSize, 50)
+	for _, concurrency := range []int{1, 2, 4, 8} {
+		e := Encoder{Concurrency: concurrency}
+		b.Run(fmt.Sprintf("encode/%d", concurrency), func(b *testing.B) {
+			b.SetBytes(parallelBenchmarkSize)
+			for i := 0; i < b.N; i++ {
+				zw := e.NewWriter(io.Discard)
+				io.Copy(zw, &repeatReader{data: block, size: parallelBenchmarkSize})
+				zw.Close()
+			}
+		})
+
+		d := Decoder{Concurrency: concurrency}
+		b.Run(fmt.Sprintf("decode/%d", concurrency), func(b *testing.B) {
+			b.SetBytes(parallelBenchmarkSize)
+			for i := 0; i < b.N; i++ {
+				stream := repeatedStream(Encoder{}, block, int(parallelBenchmarkSize)/len(block))
+				if _, err := io.Copy(io.Discard, d.NewReader(stream)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
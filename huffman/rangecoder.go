@@ -0,0 +1,123 @@
+package huffman
+
+import (
+	"fmt"
+	"io"
+)
+
+// ranges under this are renormalized by shifting out their top byte
+const rangeTop uint32 = 1 << 24
+
+// RangeCoder codes bytes with a range coder, the integer form of arithmetic
+// coding: each byte narrows an interval in proportion to its frequency in
+// the block, taking a fractional number of bits instead of the whole bits
+// of a Huffman code. The model, the frequencies of the block, comes first.
+// The coder is the one of LZMA, carrying into bytes already written
+// through a cache of pending bytes.
+type RangeCoder struct{}
+
+func (RangeCoder) Encode(w io.Writer, data []byte) (int, error) {
+	m := newModel(data)
+	n, err := m.write(w)
+	if err != nil {
+		return n, err
+	}
+
+	e := rangeEncoder{rng: 0xffff_ffff, pending: 1}
+	for _, b := range data {
+		e.encode(m.starts[b], m.freqs[b])
+	}
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+	written, err := w.Write(e.out)
+	return n + written, err
+}
+
+func (RangeCoder) Decode(r io.Reader, n int) ([]byte, error) {
+	br := byteReader(r)
+	m, err := readModel(br)
+	if err != nil {
+		return nil, err
+	}
+	symbols := m.symbols()
+
+	d := rangeDecoder{r: br, rng: 0xffff_ffff}
+	// the first byte is the initial cache of the encoder, always 0
+	for i := 0; i < 5; i++ {
+		if err := d.shift(); err != nil {
+			return nil, err
+		}
+	}
+
+	data := make([]byte, n)
+	for i := range data {
+		r := d.rng >> modelBits
+		slot := d.code / r
+		if slot >= modelTotal {
+			return nil, fmt.Errorf("%w: range code past the end of the model", errModel)
+		}
+		b := symbols[slot]
+		d.code -= r * m.starts[b]
+		d.rng = r * m.freqs[b]
+		for d.rng < rangeTop {
+			d.rng <<= 8
+			if err := d.shift(); err != nil {
+				return nil, err
+			}
+		}
+		data[i] = b
+	}
+	return data, nil
+}
+
+type rangeEncoder struct {
+	low     uint64 // start of the interval, with a carry in bit 32
+	rng     uint32 // size of the interval
+	cache   byte   // last byte shifted out, still subject to a carry
+	pending int    // number of bytes held back: the cache and 0xff bytes after it
+	out     []byte
+}
+
+// encode narrows the interval to the part of the byte starting at start in
+// the model with freq.
+func (e *rangeEncoder) encode(start, freq uint32) {
+	r := e.rng >> modelBits
+	e.low += uint64(r * start)
+	e.rng = r * freq
+	for e.rng < rangeTop {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+// shiftLow shifts the top byte of low out. It's held back while it's 0xff,
+// since a carry could still turn it to 0 and increment the byte before.
+func (e *rangeEncoder) shiftLow() {
+	if uint32(e.low) < 0xff00_0000 || e.low>>32 != 0 {
+		carry := byte(e.low >> 32)
+		b := e.cache
+		for ; e.pending > 0; e.pending-- {
+			e.out = append(e.out, b+carry)
+			b = 0xff
+		}
+		e.cache = byte(e.low >> 24)
+	}
+	e.pending++
+	e.low = (e.low & 0x00ff_ffff) << 8
+}
+
+type rangeDecoder struct {
+	r    io.ByteReader
+	code uint32 // position of the encoded value in the interval
+	rng  uint32
+}
+
+func (d *rangeDecoder) shift() error {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("error reading range code %w", unexpected(err))
+	}
+	d.code = d.code<<8 | uint32(b)
+	return nil
+}
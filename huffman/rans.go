@@ -0,0 +1,86 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// lower bound of the state of the rANS coder, which is kept in
+// [ransLow, ransLow<<8) between bytes
+const ransLow uint32 = 1 << 23
+
+// RANSCoder codes bytes with rANS, range asymmetric numeral systems: the
+// state, a single integer, grows by a factor of the inverse of the
+// probability of each byte coded, and its low bytes are written out as it
+// grows. It's about as tight as a range coder with a cheaper decoder.
+//
+// Bytes are decoded in the reverse of the order they're encoded in, so
+// the encoder codes the block backwards: the model comes first, then the
+// final state, little-endian, then the bytes written out, last one first.
+type RANSCoder struct{}
+
+func (RANSCoder) Encode(w io.Writer, data []byte) (int, error) {
+	m := newModel(data)
+	n, err := m.write(w)
+	if err != nil {
+		return n, err
+	}
+
+	// bytes written out, in reverse order
+	var out []byte
+	state := ransLow
+	for i := len(data) - 1; i >= 0; i-- {
+		start, freq := m.starts[data[i]], m.freqs[data[i]]
+		// keep the next state under ransLow<<8
+		limit := (ransLow >> modelBits << 8) * freq
+		for state >= limit {
+			out = append(out, byte(state))
+			state >>= 8
+		}
+		state = state/freq<<modelBits + state%freq + start
+	}
+	out = binary.BigEndian.AppendUint32(out, state)
+	slices.Reverse(out)
+
+	written, err := w.Write(out)
+	return n + written, err
+}
+
+func (RANSCoder) Decode(r io.Reader, n int) ([]byte, error) {
+	br := byteReader(r)
+	m, err := readModel(br)
+	if err != nil {
+		return nil, err
+	}
+	symbols := m.symbols()
+
+	var state uint32
+	for i := 0; i < 4; i++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("error reading rANS state %w", unexpected(err))
+		}
+		state |= uint32(b) << (8 * i)
+	}
+
+	data := make([]byte, n)
+	for i := range data {
+		slot := state & (modelTotal - 1)
+		b := symbols[slot]
+		state = m.freqs[b]*(state>>modelBits) + slot - m.starts[b]
+		for state < ransLow {
+			next, err := br.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("error reading rANS code %w", unexpected(err))
+			}
+			state = state<<8 | uint32(next)
+		}
+		data[i] = b
+	}
+	if state != ransLow {
+		return nil, fmt.Errorf("%w: rANS state %#x at the end of the block instead of %#x", errModel, state, ransLow)
+	}
+	return data, nil
+}
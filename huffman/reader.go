@@ -0,0 +1,323 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// size of the chunks a streaming BitReader reads from its source
+const readChunkSize int = 32 * 1024
+
+// BitReader reads bits most-significant first from a buffer written by a BitWriter.
+//
+// Bits are loaded into a 64-bit register a whole word at a time when
+// possible, and codes are peeked and consumed from the register.
+type BitReader struct {
+	src     io.Reader // nil when the whole input is in buf
+	eof     bool      // src is exhausted
+	padded  bool      // the input has no trailing cursor byte, only padding up to a whole byte
+	offset  int       // number of bytes dropped from the front of buf
+	buf     []byte
+	buf_len int
+	idx     int    // index of the next byte of buf to load into acc
+	acc     uint64 // loaded bits, the next one to read in the highest bit
+	nbits   uint8  // number of loaded bits in acc
+	end     int    // bit position of the end of the bitstream, once eof
+}
+
+// NewBitReader returns a BitReader over data, which must end with the
+// trailing cursor byte written by BitWriter.Flush.
+func NewBitReader(data []byte) *BitReader {
+	r := &BitReader{
+		eof:     true,
+		buf:     data,
+		buf_len: len(data),
+	}
+	r.setEnd()
+	return r
+}
+
+// NewBitReaderFrom returns a BitReader reading from src in chunks, so that
+// only a small window of the input is held in memory. The input must end
+// with the trailing cursor byte written by BitWriter.Flush.
+func NewBitReaderFrom(src io.Reader) *BitReader {
+	return &BitReader{
+		src: src,
+		buf: make([]byte, 0, readChunkSize),
+	}
+}
+
+// NewPaddedBitReader returns a BitReader reading from src in chunks, for
+// input written by BitWriter.FlushPadded: the bitstream runs up to the end
+// of src, and the reader of the bitstream knows where to stop.
+func NewPaddedBitReader(src io.Reader) *BitReader {
+	r := NewBitReaderFrom(src)
+	r.padded = true
+	return r
+}
+
+// setEnd locates the end of the bitstream from the trailing cursor byte.
+func (r *BitReader) setEnd() {
+	// in a correct huffman-encoded file, the last byte is an int between 0-7
+	// for example if it's 3, then we only read the first 3 bits of the second-last byte
+	// if it's 0, we read the whole 8 bits of the second-last byte
+	// then we reach EOF
+	if r.padded || r.buf_len == 0 {
+		r.end = 8 * (r.offset + r.buf_len)
+		return
+	}
+	second_last_byte_read_size := int(r.buf[r.buf_len-1] % 8)
+	r.end = 8 * (r.offset + r.buf_len - 1)
+	if second_last_byte_read_size != 0 {
+		r.end -= 8 - second_last_byte_read_size
+	}
+}
+
+// fill reads from src until at least 16 bytes are left after idx, or src
+// is exhausted.
+func (r *BitReader) fill() error {
+	for !r.eof && r.buf_len-r.idx < 16 {
+		// drop the bytes already loaded
+		r.offset += r.idx
+		r.buf_len = copy(r.buf[:cap(r.buf)], r.buf[r.idx:r.buf_len])
+		r.buf = r.buf[:r.buf_len]
+		r.idx = 0
+
+		n, err := r.src.Read(r.buf[r.buf_len:cap(r.buf)])
+		r.buf_len += n
+		r.buf = r.buf[:r.buf_len]
+
+		if err == io.EOF {
+			r.eof = true
+			r.setEnd()
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refill loads bytes into acc until it holds more than 56 bits or the
+// bitstream is over.
+func (r *BitReader) refill() error {
+	for r.nbits <= 56 {
+		// until src is exhausted the last 2 bytes may be the trailing cursor
+		// byte and a padded byte, they're only loaded once eof
+		limit := r.buf_len
+		if !r.padded {
+			limit--
+			if !r.eof {
+				limit--
+			}
+		}
+
+		if r.idx+8 <= limit {
+			// load a whole word, the bytes that don't fit in acc are loaded
+			// again by the next refill
+			word := binary.BigEndian.Uint64(r.buf[r.idx:])
+			r.acc |= word >> r.nbits
+			loaded := (64 - r.nbits) / 8
+			r.idx += int(loaded)
+			r.nbits += loaded * 8
+			continue
+		}
+
+		if r.idx < limit {
+			r.acc |= uint64(r.buf[r.idx]) << (56 - r.nbits)
+			r.idx++
+			r.nbits += 8
+			continue
+		}
+
+		if r.eof {
+			break
+		}
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// position returns the index of the next bit to read since the start of the input.
+func (r *BitReader) position() int {
+	return 8*(r.offset+r.idx) - int(r.nbits)
+}
+
+// available returns how many of the loaded bits are in the bitstream.
+func (r *BitReader) available() int {
+	if !r.eof {
+		return int(r.nbits)
+	}
+	return max(min(r.end-r.position(), int(r.nbits)), 0)
+}
+
+// PeekBits returns the next n bits without consuming them, n being at most
+// 56. Bits past the end of the bitstream read as 0, the second value
+// returned is the number of bits that are actually in the bitstream.
+func (r *BitReader) PeekBits(n uint8) (uint64, uint8, error) {
+	if r.nbits < n {
+		if err := r.refill(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if n == 0 {
+		return 0, 0, nil
+	}
+
+	available := uint8(min(r.available(), int(n)))
+	value := r.acc >> (64 - n)
+	if available < n {
+		// clear the bits past the end of the bitstream
+		value &^= 1<<(n-available) - 1
+	}
+	return value, available, nil
+}
+
+// SkipBits consumes n bits returned by PeekBits.
+func (r *BitReader) SkipBits(n uint8) {
+	r.acc <<= n
+	r.nbits -= n
+}
+
+// ReadBits reads n bits, most significant first, n being at most 56.
+// Returns io.EOF if the bitstream is over, io.ErrUnexpectedEOF if it ends
+// before n bits.
+func (r *BitReader) ReadBits(n uint8) (uint64, error) {
+	value, available, err := r.PeekBits(n)
+	if err != nil {
+		return 0, err
+	}
+	if available < n {
+		if available == 0 {
+			return 0, io.EOF
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.SkipBits(n)
+	return value, nil
+}
+
+// ReadBit reads the next bit. Returns io.EOF past the last bit of the bitstream.
+func (r *BitReader) ReadBit() (uint8, error) {
+	bit, err := r.ReadBits(1)
+	return uint8(bit), err
+}
+
+// ReadByte reads the next 8 bits, whatever the current bit alignment.
+func (r *BitReader) ReadByte() (byte, error) {
+	b, err := r.ReadBits(8)
+	if err == io.ErrUnexpectedEOF {
+		// normally this shouldn't happen in a correct huffman-encoded file
+		return 0, fmt.Errorf("can't read byte at bit %d, only %d bits left", r.position(), r.available())
+	}
+	return byte(b), err
+}
+
+// ReadTree reads a tree serialized by BitWriter.WriteTree. read_start_index
+// is the bit position the tree starts at and tree_size its size in bits.
+func (r *BitReader) ReadTree(read_start_index int, tree_size int) (*Node, error) {
+
+	read_current_index := r.position()
+
+	if read_current_index-read_start_index >= tree_size {
+		return nil, io.EOF
+	}
+
+	bit, err := r.ReadBit()
+	if err != nil {
+		return nil, err
+	}
+
+	if bit == 1 {
+		ch, read_byte_err := r.ReadByte()
+		if read_byte_err != nil {
+			return nil, read_byte_err
+		}
+		return &Node{ch: ch}, nil
+	}
+
+	left_node, read_left_err := r.ReadTree(read_start_index, tree_size)
+	if read_left_err != nil {
+		if read_left_err == io.EOF {
+			return nil, nil
+		}
+		return nil, read_left_err
+	}
+	right_node, read_right_err := r.ReadTree(read_start_index, tree_size)
+	if read_right_err != nil {
+		if read_left_err == io.EOF {
+			return nil, nil
+		}
+		return nil, read_right_err
+	}
+	return &Node{
+		Left:  left_node,
+		Right: right_node,
+	}, nil
+}
+
+// ReadCodeLengths reads a table serialized by BitWriter.WriteCodeLengths.
+func (r *BitReader) ReadCodeLengths() ([256]uint8, error) {
+	var lengths [256]uint8
+	err := r.readLengths(lengths[:])
+	return lengths, err
+}
+
+// readLengths reads the code length of each symbol of an alphabet of
+// len(lengths) symbols, serialized by BitWriter.writeLengths.
+func (r *BitReader) readLengths(lengths []uint8) error {
+	for i := 0; i < len(lengths); {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return err
+		}
+
+		if bit == 0 {
+			length, err := r.ReadBits(6)
+			if err != nil {
+				return err
+			}
+			if length == 0 {
+				return fmt.Errorf("huffman: zero code length for symbol %d", i)
+			}
+			lengths[i] = uint8(length)
+			i++
+			continue
+		}
+
+		run, err := r.ReadBits(8)
+		if err != nil {
+			return err
+		}
+		i += int(run) + 1
+		if i > len(lengths) {
+			return fmt.Errorf("huffman: run of %d symbols past the end of the code lengths table", run+1)
+		}
+	}
+	return nil
+}
+
+// remaining returns a reader of the input following the byte of the next
+// bit to read, for data stored after a bitstream padded to a whole byte.
+func (r *BitReader) remaining() io.Reader {
+	rest := bytes.NewReader(r.buffered())
+	if r.src == nil || r.eof {
+		return rest
+	}
+	return io.MultiReader(rest, r.src)
+}
+
+// buffered returns the bytes read from the source following the byte of the
+// next bit to read: the whole bytes left in acc, which buf may have dropped,
+// then the bytes of buf not loaded yet.
+func (r *BitReader) buffered() []byte {
+	rest := make([]byte, 0, int(r.nbits/8)+r.buf_len-r.idx)
+	for acc, n := r.acc<<(r.nbits%8), r.nbits/8; n > 0; n-- {
+		rest = append(rest, byte(acc>>56))
+		acc <<= 8
+	}
+	return append(rest, r.buf[r.idx:r.buf_len]...)
+}
@@ -1,4 +1,4 @@
-package main
+package huffman
 
 import (
 	"fmt"
@@ -36,9 +36,8 @@ func TestReadBit_ShouldFail(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(fmt.Sprintf("Test %d", scenarioIdx), func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
 			_, err := r.ReadBit()
 
@@ -93,9 +92,8 @@ func TestReadByte_ShouldFail(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
 			b, err := r.ReadByte()
 
@@ -150,13 +148,12 @@ func TestReadTree_ShouldFail(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
-			start_index := r.idx*8 + int(r.cursor)
+			start_index := r.position()
 			tree, err := r.ReadTree(start_index, scenario.mock_tree_encoded_size)
-			current_index := r.idx*8 + int(r.cursor)
+			current_index := r.position()
 
 			equal_trees := areTreesEqual(tree, scenario.mock_tree)
 			if err == nil ||
@@ -204,9 +201,8 @@ func TestReadBit_ShouldSucceed(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
 			bit, err := r.ReadBit()
 
@@ -260,16 +256,16 @@ func TestReadByte_ShouldSucceed(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
 			b, err := r.ReadByte()
 
-			if err != nil || scenario.expected_byte != b || r.cursor != scenario.cursor || r.idx != scenario.idx+1 {
+			expected_position := 8*(scenario.idx+1) + int(scenario.cursor)
+			if err != nil || scenario.expected_byte != b || r.position() != expected_position {
 				t.Fatalf(`Test %d Failed.
-				Got: %08b, %v, (idx=%d, cursor=%d)
-				Wanted: %08b, <nil>, (idx=%d, cursor=%d)`, scenarioIdx, b, err, r.idx, r.cursor, scenario.expected_byte, scenario.idx+1, scenario.cursor)
+				Got: %08b, %v, (position=%d)
+				Wanted: %08b, <nil>, (position=%d)`, scenarioIdx, b, err, r.position(), scenario.expected_byte, expected_position)
 			}
 		})
 	}
@@ -307,13 +303,12 @@ func TestReadTree_ShouldSucceed(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			r := GetReader(scenario.data)
-			r.cursor = scenario.cursor
-			r.idx = scenario.idx
+			r := NewBitReader(scenario.data)
+			r.seek(scenario.idx, scenario.cursor)
 
-			start_index := r.idx*8 + int(r.cursor)
+			start_index := r.position()
 			tree, err := r.ReadTree(start_index, MOCK_TREE_ENCODED_SIZE)
-			current_index := r.idx*8 + int(r.cursor)
+			current_index := r.position()
 
 			equal_trees := areTreesEqual(tree, MOCK_TREE)
 			if err != nil ||
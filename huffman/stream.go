@@ -0,0 +1,725 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// upper bound of the size of an encoded block: codes of a block are shorter
+// than 32 bits, plus the code lengths table
+const maxEncodedBlockSize int = 4*MaxBlockSize + 1024
+
+var errClosed = errors.New("huffman: write to closed Writer")
+
+// Writer is an io.WriteCloser that Huffman-encodes what is written to it.
+//
+// Writer keeps at most one block of input in memory: each time a block is
+// full it's encoded with its own tree and written to the underlying
+// io.Writer. With a Concurrency over 1, up to Concurrency blocks are
+// encoded at once, each on a goroutine, and written out in order. In
+// adaptive mode, input is encoded as it's written and Flush writes out every
+// whole byte of the encoding. With a DEFLATE Format, each block is a dynamic
+// Huffman block.
+type Writer struct {
+	w        io.Writer
+	enc      *Encoder
+	deflate  *deflateWriter // writer of DEFLATE formats
+	data     []byte         // input of the current block
+	bw       *BitWriter     // bitstream of adaptive mode
+	adaptive *adaptiveTree  // tree of adaptive mode
+	pending  []*blockJob    // blocks being encoded, with a Concurrency over 1
+	header   bool           // the header has been written
+	length   uint64         // length of the input
+	digest   hash.Hash32
+	closed   bool
+	err      error
+}
+
+// NewWriter returns a Writer that writes the encoding of its input to w,
+// using blocks of DefaultBlockSize bytes.
+func NewWriter(w io.Writer) *Writer {
+	return (&Encoder{}).NewWriter(w)
+}
+
+// NewWriterSize returns a Writer that writes the encoding of its input to w,
+// using blocks of blockSize bytes.
+func NewWriterSize(w io.Writer, blockSize int) (*Writer, error) {
+	enc := &Encoder{BlockSize: blockSize}
+	if err := enc.Check(); err != nil {
+		return nil, err
+	}
+	return enc.NewWriter(w), nil
+}
+
+// NewWriter returns a Writer that writes the encoding of its input to w,
+// using the settings of e. Settings are checked on the first write.
+func (e *Encoder) NewWriter(w io.Writer) *Writer {
+	z := &Writer{w: w, enc: e, digest: crc32.NewIEEE()}
+	if e.Format != FormatHuffman {
+		z.deflate = newDeflateWriter(w, e)
+	}
+	return z
+}
+
+// Write encodes p, writing out every block it completes.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	if err := z.enc.Check(); err != nil {
+		return 0, err
+	}
+	if z.deflate != nil {
+		var n int
+		n, z.err = z.deflate.Write(p)
+		return n, z.err
+	}
+	block_size := z.enc.blockSize()
+
+	z.length += uint64(len(p))
+	z.digest.Write(p)
+
+	if z.enc.Adaptive {
+		if err := z.writeHeader(); err != nil {
+			return 0, err
+		}
+		for _, b := range p {
+			z.adaptive.encode(z.bw, int(b))
+		}
+		return len(p), z.bw.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		size := min(block_size-len(z.data), len(p))
+		z.data = append(z.data, p[:size]...)
+		p = p[size:]
+		n += size
+
+		if len(z.data) == block_size {
+			if err := z.writeBlock(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush encodes the pending input as a block, even if it's not full, and
+// writes it to the underlying io.Writer.
+func (z *Writer) Flush() error {
+	if z.closed {
+		return errClosed
+	}
+	if z.err != nil {
+		return z.err
+	}
+	if z.deflate != nil {
+		z.err = z.deflate.Flush()
+		return z.err
+	}
+	if z.enc.Adaptive {
+		if z.bw == nil {
+			return nil
+		}
+		_, z.err = z.bw.FlushBytes()
+		return z.err
+	}
+	if len(z.data) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	return z.writePending(len(z.pending))
+}
+
+// Close flushes the pending input and writes the end of the stream.
+// It does not close the underlying io.Writer. A stream of empty input is
+// the header, the end of the blocks and the trailer, without any block.
+func (z *Writer) Close() error {
+	if z.closed {
+		return nil
+	}
+	if z.deflate != nil {
+		if z.err == nil {
+			z.err = z.enc.Check()
+		}
+		if z.err == nil {
+			z.err = z.deflate.Close()
+		}
+		z.closed = true
+		return z.err
+	}
+	if err := z.Flush(); err != nil {
+		return err
+	}
+	z.closed = true
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+
+	if z.enc.Adaptive {
+		z.adaptive.encode(z.bw, endOfStream)
+		_, z.err = z.bw.FlushPadded()
+	} else {
+		end, _ := intToBytes(0)
+		_, z.err = z.w.Write(end)
+	}
+	if z.err != nil {
+		return z.err
+	}
+	z.err = writeTrailer(z.w, trailer{length: z.length, checksum: z.digest.Sum32()})
+	return z.err
+}
+
+func (z *Writer) writeHeader() error {
+	if z.header {
+		return nil
+	}
+	z.header = true
+	flags := FlagChecksum
+	if z.enc.Order1 {
+		flags |= FlagOrder1
+	}
+	if z.enc.Tables > 1 {
+		flags |= FlagMultiTable
+	}
+	if z.enc.Level > 0 {
+		flags |= FlagLZ77
+	}
+	if len(z.enc.Transforms) > 0 {
+		flags |= FlagTransforms
+	}
+	if z.enc.Coder != CoderHuffman {
+		flags |= FlagCoder
+	}
+	if z.enc.Alphabet != AlphabetBytes {
+		flags |= FlagSymbols
+	}
+	if z.enc.Interleaved {
+		flags |= FlagInterleaved
+	}
+	if z.enc.Adaptive {
+		flags |= FlagAdaptive
+		z.bw = NewBitWriter(z.w)
+		z.adaptive = newAdaptiveTree()
+	}
+	z.err = writeHeader(z.w, Header{Version: FormatVersion, Flags: flags})
+	if z.err == nil && flags&FlagCoder != 0 {
+		_, z.err = z.w.Write([]byte{byte(z.enc.Coder)})
+	}
+	if z.err == nil && flags&FlagSymbols != 0 {
+		_, z.err = z.w.Write([]byte{byte(z.enc.Alphabet)})
+	}
+	if z.err == nil && flags&FlagTransforms != 0 {
+		z.err = writeTransforms(z.w, z.enc.Transforms)
+	}
+	return z.err
+}
+
+func (z *Writer) writeBlock() error {
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if z.enc.concurrency() > 1 {
+		return z.queueBlock()
+	}
+
+	var block bytes.Buffer
+	if _, z.err = z.enc.encodeBlock(&block, z.data); z.err != nil {
+		return z.err
+	}
+	z.data = z.data[:0]
+
+	block_size_bytes, _ := intToBytes(uint32(block.Len()))
+	if _, z.err = z.w.Write(block_size_bytes); z.err != nil {
+		return z.err
+	}
+	_, z.err = z.w.Write(block.Bytes())
+	return z.err
+}
+
+// Reader is an io.ReadCloser that decodes a Huffman-encoded stream,
+// holding only a small window of the encoded input in memory. It also
+// inflates DEFLATE streams, raw or in gzip or zlib framing.
+type Reader struct {
+	r          io.Reader
+	format     Format        // format of the stream
+	header     *Header       // nil until read, and in DEFLATE formats
+	transforms []Transform   // transforms of the blocks, with FlagTransforms
+	br         *BitReader    // reads the current block, nil between blocks
+	block      io.Reader     // input of the current block
+	tree       *Node         // tree of the current block
+	table      *decodeTable  // decode table of tree
+	decoder    blockDecoder  // decodes the current block of a version 2 stream
+	adaptive   *adaptiveTree // tree of an adaptive stream
+	inflate    *inflater     // decodes the blocks of a DEFLATE stream
+	coder      Coder         // entropy coder of the blocks
+	alphabet   Alphabet      // alphabet of the blocks
+	workers    int           // number of blocks decoded at once
+	parallel   bool          // blocks are decoded by goroutines of their own
+	pending    []*blockJob   // blocks being decoded, in order
+	decoded    []byte        // rest of the last block decoded in parallel
+	last       bool          // the end of the stream has been read, in parallel
+	inverted   []byte        // current block with its transforms inverted, nil until decoded
+	next       int           // index of the next byte of inverted
+	length     uint64        // length of the decoded data
+	digest     hash.Hash32   // CRC-32 of the decoded data
+	end        *trailer      // trailer of the stream, read at its end
+	err        error
+}
+
+// blockDecoder decodes the symbols of a block of a version 2 stream, in one
+// of its layouts, once the tables of the block are read.
+type blockDecoder interface {
+	// decode returns the next byte of the block, read from br.
+	// Returns io.EOF past the last symbol of the block.
+	decode(br *BitReader) (byte, error)
+}
+
+// decodedBlock is a block decoded in full as its tables are read.
+type decodedBlock struct {
+	data []byte
+	next int // index of the next byte of data
+}
+
+func (d *decodedBlock) decode(br *BitReader) (byte, error) {
+	if d.next == len(d.data) {
+		return 0, io.EOF
+	}
+	d.next++
+	return d.data[d.next-1], nil
+}
+
+// NewReader returns a Reader decoding the data read from r, a stream of
+// this package or a gzip or zlib stream.
+func NewReader(r io.Reader) *Reader {
+	return (&Decoder{}).NewReader(r)
+}
+
+// NewReader returns a Reader decoding the data read from r, using the
+// settings of d.
+func (d *Decoder) NewReader(r io.Reader) *Reader {
+	z := &Reader{r: r, digest: crc32.NewIEEE(), workers: max(d.Concurrency, 1)}
+	if d.Format == FormatDeflate {
+		z.setFormat(FormatDeflate)
+	}
+	return z
+}
+
+// Header returns the header of the stream, nil until the first call to Read
+// and for DEFLATE streams.
+func (z *Reader) Header() *Header {
+	return z.header
+}
+
+// Transforms returns the transforms applied to the blocks of the stream,
+// known after the first call to Read.
+func (z *Reader) Transforms() []Transform {
+	return z.transforms
+}
+
+// Coder returns the entropy coder of the blocks of the stream, known after
+// the first call to Read.
+func (z *Reader) Coder() Coder {
+	return z.coder
+}
+
+// Alphabet returns the alphabet of the blocks of the stream, known after the
+// first call to Read.
+func (z *Reader) Alphabet() Alphabet {
+	return z.alphabet
+}
+
+// Tree returns the tree of the block being decoded, nil until the first
+// call to Read, in adaptive, order-1, multi-table and LZ77 modes, with
+// other coders than Huffman, alphabets other than bytes and blocks decoded
+// in parallel.
+func (z *Reader) Tree() *Node {
+	return z.tree
+}
+
+// Read decodes up to len(p) bytes into p.
+func (z *Reader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	n := 0
+	checked := 0 // bytes of p added to length and digest
+	for n < len(p) {
+		if len(z.decoded) > 0 {
+			copied := copy(p[n:], z.decoded)
+			z.decoded = z.decoded[copied:]
+			n += copied
+			continue
+		}
+		if d, ok := z.decoder.(*decodedBlock); ok && z.transforms == nil && d.next < len(d.data) {
+			// blocks decoded in full are copied out at once
+			copied := copy(p[n:], d.data[d.next:])
+			d.next += copied
+			n += copied
+			continue
+		}
+		if z.br == nil {
+			z.err = z.nextBlock()
+			if z.err != nil {
+				break
+			}
+			if z.parallel {
+				continue
+			}
+		}
+
+		b, err := z.readSymbol()
+		if err == io.EOF && z.format == FormatGzip {
+			// each member of a gzip stream has a trailer of its own
+			z.err = z.nextMember(p[checked:n])
+			checked = n
+			if z.err != nil {
+				break
+			}
+			continue
+		}
+		if err == io.EOF {
+			// end of the block
+			z.br = nil
+			if z.format != FormatHuffman || z.header.Version == LegacyVersion || z.adaptive != nil {
+				// DEFLATE streams read their own blocks, legacy and adaptive
+				// streams are a single block
+				z.err = io.EOF
+				break
+			}
+			continue
+		}
+		if err != nil {
+			z.err = err
+			break
+		}
+		p[n] = b
+		n++
+	}
+
+	z.length += uint64(n - checked)
+	z.digest.Write(p[checked:n])
+	if z.err == io.EOF && z.end != nil {
+		if err := z.end.verify(z.trailerLength(), z.digest.Sum32()); err != nil {
+			z.err = err
+		}
+	}
+
+	if n > 0 && z.err == io.EOF {
+		return n, nil
+	}
+	return n, z.err
+}
+
+// Close stops decoding, following reads return an error.
+// It does not close the underlying io.Reader.
+func (z *Reader) Close() error {
+	if z.err == nil || z.err == io.EOF {
+		z.err = errors.New("huffman: read from closed Reader")
+	}
+	return nil
+}
+
+// nextBlock reads the size of the next block and rebuilds its tree,
+// reading the header of the stream first. Returns io.EOF at the end of the
+// stream.
+func (z *Reader) nextBlock() error {
+	if z.format != FormatHuffman {
+		return z.startDeflate()
+	}
+	if z.header == nil {
+		if err := z.detectFormat(); err != nil {
+			return err
+		}
+		if z.format != FormatHuffman {
+			return z.startDeflate()
+		}
+		header, prefix, err := readHeader(z.r)
+		if err != nil {
+			return err
+		}
+		z.header = &header
+		if header.Flags&FlagCoder != 0 {
+			if z.coder, err = readCoder(z.r); err != nil {
+				return err
+			}
+		}
+		if header.Flags&FlagSymbols != 0 {
+			if header.Flags&FlagAdaptive != 0 {
+				return fmt.Errorf("%w: symbols in an adaptive stream", ErrFormat)
+			}
+			if z.alphabet, err = readAlphabet(z.r); err != nil {
+				return err
+			}
+		}
+		if header.Flags&FlagTransforms != 0 {
+			if header.Flags&FlagAdaptive != 0 {
+				return fmt.Errorf("%w: transforms in an adaptive stream", ErrFormat)
+			}
+			if z.transforms, err = readTransforms(z.r); err != nil {
+				return err
+			}
+		}
+		if header.Version == LegacyVersion {
+			return z.readLegacyTree(bytesToInt(prefix))
+		}
+		if header.Flags&FlagAdaptive != 0 {
+			z.br = NewPaddedBitReader(z.r)
+			z.adaptive = newAdaptiveTree()
+			return nil
+		}
+		z.parallel = z.workers > 1 && header.Version >= 2
+	}
+
+	if z.parallel {
+		return z.nextDecodedBlock()
+	}
+
+	block_size, err := z.readBlockSize()
+	if err != nil {
+		return err
+	}
+	return z.startBlock(io.LimitReader(z.r, int64(block_size)))
+}
+
+// readBlockSize reads the size of the next block. Returns io.EOF at the end
+// of the stream, once the trailer is read.
+func (z *Reader) readBlockSize() (int, error) {
+	block_size_bytes := make([]byte, 4)
+	if _, err := io.ReadFull(z.r, block_size_bytes); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, fmt.Errorf("error reading block size %w", err)
+	}
+
+	block_size := int(bytesToInt(block_size_bytes))
+	if block_size == 0 {
+		if z.header.Flags&FlagChecksum != 0 {
+			end, err := readTrailer(z.r)
+			if err != nil {
+				return 0, err
+			}
+			z.end = &end
+		}
+		return 0, io.EOF
+	}
+	if block_size > maxEncodedBlockSize {
+		return 0, fmt.Errorf("huffman: block size %d exceeds %d bytes", block_size, maxEncodedBlockSize)
+	}
+	return block_size, nil
+}
+
+// startBlock reads the symbol count and the tables of block, the input of
+// the next block.
+func (z *Reader) startBlock(block io.Reader) error {
+	z.block = block
+	if z.header.Version == 1 {
+		z.br = NewBitReaderFrom(z.block)
+		return z.readTree()
+	}
+
+	z.br = NewPaddedBitReader(z.block)
+	count, read_count_err := binary.ReadUvarint(z.br)
+	if read_count_err != nil {
+		if read_count_err == io.EOF {
+			read_count_err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("error reading symbol count %w", read_count_err)
+	}
+	max_count := MaxBlockSize
+	if z.transforms != nil {
+		max_count = maxTransformedSize
+	}
+	if count == 0 || count > uint64(max_count) {
+		return fmt.Errorf("huffman: invalid block symbol count %d", count)
+	}
+
+	var err error
+	z.tree = nil
+	switch {
+	case z.coder != CoderHuffman:
+		var data []byte
+		data, err = z.coder.EntropyCoder().Decode(z.br.remaining(), int(count))
+		z.decoder = &decodedBlock{data: data}
+	case z.alphabet != AlphabetBytes:
+		z.decoder, err = z.readDictionary(count)
+	case z.header.Flags&FlagOrder1 != 0:
+		z.decoder, err = z.readContextTables(count)
+	case z.header.Flags&FlagMultiTable != 0:
+		z.decoder, err = z.readMultiTables(count)
+	case z.header.Flags&FlagLZ77 != 0:
+		z.decoder, err = z.readLZTables(count)
+	case z.header.Flags&FlagInterleaved != 0:
+		z.decoder, err = z.readStreams(count)
+	default:
+		c := HuffmanCoder{}
+		var data []byte
+		data, err = c.Decode(z.br.remaining(), int(count))
+		z.tree, z.decoder = c.tree, &decodedBlock{data: data}
+	}
+	return err
+}
+
+func (z *Reader) readTree() error {
+	lengths, read_lengths_err := z.br.ReadCodeLengths()
+	if read_lengths_err != nil {
+		if read_lengths_err == io.EOF {
+			read_lengths_err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("error reading code lengths %w", read_lengths_err)
+	}
+
+	root, build_tree_err := treeFromCodeLengths(lengths)
+	if build_tree_err != nil {
+		return build_tree_err
+	}
+	z.tree = root
+	z.table = newDecodeTable(root)
+
+	if __DEBUG__ {
+		root.Display(0)
+	}
+	return nil
+}
+
+// readLegacyTree reads the tree of a legacy stream, serialized in full right
+// after its size.
+func (z *Reader) readLegacyTree(tree_size uint32) error {
+	z.br = NewBitReaderFrom(z.r)
+	root, read_tree_err := z.br.ReadTree(z.br.position(), int(tree_size))
+	if read_tree_err == nil && root == nil {
+		read_tree_err = io.ErrUnexpectedEOF
+	}
+	if read_tree_err != nil {
+		if read_tree_err == io.EOF {
+			read_tree_err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("error reading tree %w", read_tree_err)
+	}
+	z.tree = root
+	z.table = newDecodeTable(root)
+	return nil
+}
+
+// readSymbol decodes the next symbol of the block.
+// Returns io.EOF at the end of the block.
+func (z *Reader) readSymbol() (byte, error) {
+	if z.transforms != nil {
+		return z.readTransformedSymbol()
+	}
+	return z.readCodedSymbol()
+}
+
+// readTransformedSymbol returns the next byte of a transformed block, which
+// is decoded in full to invert its transforms. Returns io.EOF at the end of
+// the block.
+func (z *Reader) readTransformedSymbol() (byte, error) {
+	if z.inverted == nil {
+		transformed, err := z.readCodedBlock()
+		if err != nil {
+			return 0, err
+		}
+		inverted, err := invertTransforms(z.transforms, transformed)
+		if err != nil {
+			return 0, err
+		}
+		z.inverted, z.next = inverted, 0
+	}
+
+	if z.next == len(z.inverted) {
+		z.inverted = nil
+		return 0, io.EOF
+	}
+	z.next++
+	return z.inverted[z.next-1], nil
+}
+
+// readCodedBlock decodes the rest of the block, as it was coded.
+func (z *Reader) readCodedBlock() ([]byte, error) {
+	if d, ok := z.decoder.(*decodedBlock); ok {
+		z.decoder = nil
+		if err := z.endBlock(); err != io.EOF {
+			return nil, err
+		}
+		return d.data[d.next:], nil
+	}
+
+	var coded []byte
+	for {
+		b, err := z.readCodedSymbol()
+		if err == io.EOF {
+			return coded, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		coded = append(coded, b)
+	}
+}
+
+// readCodedSymbol decodes the next symbol of the block, as it was coded.
+// Returns io.EOF at the end of the block.
+func (z *Reader) readCodedSymbol() (byte, error) {
+	if z.adaptive != nil {
+		return z.readAdaptiveSymbol()
+	}
+	if z.format != FormatHuffman {
+		return z.readDeflateSymbol()
+	}
+	if z.header.Version < 2 {
+		return z.table.decode(z.br)
+	}
+
+	b, err := z.decoder.decode(z.br)
+	if err == io.EOF {
+		z.decoder = nil
+		return 0, z.endBlock()
+	}
+	return b, err
+}
+
+// readAdaptiveSymbol decodes the next symbol of an adaptive stream, reading
+// the trailer after the end of stream symbol. Returns io.EOF at the end of
+// the stream.
+func (z *Reader) readAdaptiveSymbol() (byte, error) {
+	symbol, err := z.adaptive.decode(z.br)
+	if err == io.EOF {
+		return 0, fmt.Errorf("huffman: stream ends without end of stream symbol %w", io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if symbol != endOfStream {
+		return byte(symbol), nil
+	}
+
+	if z.header.Flags&FlagChecksum != 0 {
+		end, err := readTrailer(z.br.remaining())
+		if err != nil {
+			return 0, err
+		}
+		z.end = &end
+	}
+	return 0, io.EOF
+}
+
+// endBlock skips the padding of the block, and whatever follows it, up to
+// the next block. Returns io.EOF, the end of the block.
+func (z *Reader) endBlock() error {
+	if _, err := io.Copy(io.Discard, z.block); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,151 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func randomData(size int, alphabet int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	data := make([]byte, size)
+	for i := range data {
+		// skew the distribution so that codes have different lengths
+		data[i] = byte(rng.Intn(alphabet) * rng.Intn(alphabet) / alphabet)
+	}
+	return data
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		write_size  int
+		block_size  int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "text in one write",
+			data:        []byte("huffman coding and decoding in golang"),
+			write_size:  100,
+		},
+		{
+			description: "text in single byte writes",
+			data:        []byte("huffman coding and decoding in golang"),
+			write_size:  1,
+		},
+		{
+			description: "binary data bigger than the read and write chunks",
+			data:        randomData(200_000, 256, 1),
+			write_size:  4096,
+			block_size:  MaxBlockSize,
+		},
+		{
+			description: "several blocks, last one partial",
+			data:        append(randomData(3*MinBlockSize, 16, 2), randomData(1000, 256, 3)...),
+			write_size:  10_000,
+			block_size:  MinBlockSize,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			zw := NewWriter(&encoded)
+			if scenario.block_size != 0 {
+				zw, _ = NewWriterSize(&encoded, scenario.block_size)
+			}
+			for i := 0; i < len(scenario.data); i += scenario.write_size {
+				end := min(i+scenario.write_size, len(scenario.data))
+				if _, err := zw.Write(scenario.data[i:end]); err != nil {
+					t.Fatalf("Test %d Failed. write error: %v", scenarioIdx, err)
+				}
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatalf("Test %d Failed. close error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v,
+				Wanted: %d bytes, <nil>`, scenarioIdx, len(decoded), err, len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestStreamRead_ShouldFail(t *testing.T) {
+	encoded, _ := Encode([]byte("huffman coding and decoding in golang"))
+
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "truncated tree size",
+			data:        encoded[:3],
+		},
+		{
+			description: "truncated tree",
+			data:        encoded[:10],
+		},
+		{
+			description: "missing end of stream",
+			data:        encoded[:len(encoded)-4],
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			_, err := io.ReadAll(NewReader(bytes.NewReader(scenario.data)))
+
+			if err == nil {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
+
+func TestNewWriterSize_ShouldFail(t *testing.T) {
+	for _, block_size := range []int{-1, MinBlockSize - 1, MaxBlockSize + 1} {
+		if _, err := NewWriterSize(io.Discard, block_size); err == nil {
+			t.Fatalf("NewWriterSize(%d): got err <nil>, wanted err != <nil>", block_size)
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var encoded bytes.Buffer
+	zw := NewWriter(&encoded)
+	zw.Write([]byte("first block"))
+	zw.Flush()
+	zw.Write([]byte("second block"))
+	zw.Close()
+
+	decoded, err := io.ReadAll(NewReader(&encoded))
+	if err != nil || string(decoded) != "first blocksecond block" {
+		t.Fatalf(`Test Flush Failed.
+		Got: %q, %v,
+		Wanted: "first blocksecond block", <nil>`, decoded, err)
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	zw := NewWriter(io.Discard)
+	zw.Write([]byte("abc"))
+	zw.Close()
+
+	if _, err := zw.Write([]byte("abc")); err == nil {
+		t.Fatalf("Write after Close: got err <nil>, wanted err != <nil>")
+	}
+}
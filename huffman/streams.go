@@ -0,0 +1,191 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// number of bitstreams of an interleaved block
+const interleavedStreams int = 4
+
+// segments returns the bounds of the segment of a block of n symbols coded
+// by each stream: the first ones hold (n+3)/4 symbols, the last one what's
+// left.
+func segments(n int) [interleavedStreams + 1]int {
+	var bounds [interleavedStreams + 1]int
+	size := (n + interleavedStreams - 1) / interleavedStreams
+	for i := range bounds {
+		bounds[i] = min(i*size, n)
+	}
+	return bounds
+}
+
+// encodeInterleavedBlock writes data as a single block coded as 4
+// bitstreams, without its size prefix. The code lengths table, padded to a
+// whole byte, is followed by the size of the first 3 streams, varints, then
+// by the 4 streams, each padded to a whole byte.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeInterleavedBlock(w io.Writer, data []byte) (int, error) {
+	c := HuffmanCoder{MaxCodeLength: e.MaxCodeLength}
+	if err := c.build(data); err != nil {
+		return 0, err
+	}
+	e.tree, e.codes = c.tree, c.codes
+
+	var block bytes.Buffer
+	block.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	bw := BitWriter{io_writer: &block}
+	bw.WriteCodeLengths(c.codes.Lengths())
+	bw.FlushPadded()
+
+	var streams [interleavedStreams]bytes.Buffer
+	bounds := segments(len(data))
+	for i := range streams {
+		sw := BitWriter{io_writer: &streams[i]}
+		for _, b := range data[bounds[i]:bounds[i+1]] {
+			sw.WriteCode(c.codes[b])
+		}
+		sw.FlushPadded()
+	}
+
+	var jump []byte
+	for _, stream := range streams[:interleavedStreams-1] {
+		jump = binary.AppendUvarint(jump, uint64(stream.Len()))
+	}
+	block.Write(jump)
+	for _, stream := range streams {
+		block.Write(stream.Bytes())
+	}
+	return w.Write(block.Bytes())
+}
+
+// readStreams reads the code lengths table and the bitstreams of an
+// interleaved block of count symbols, and decodes the block in full: the
+// streams don't depend on each other, so decoding a symbol of each in turn
+// keeps 4 decodes in flight instead of one.
+func (z *Reader) readStreams(count uint64) (*decodedBlock, error) {
+	if err := z.readTree(); err != nil {
+		return nil, err
+	}
+	rest, err := io.ReadAll(z.br.remaining())
+	if err != nil {
+		return nil, err
+	}
+
+	var sizes [interleavedStreams]int
+	total := 0
+	for i := range sizes[:interleavedStreams-1] {
+		size, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return nil, fmt.Errorf("error reading jump table %w", io.ErrUnexpectedEOF)
+		}
+		rest = rest[n:]
+		if len(rest) < total || size > uint64(len(rest)-total) {
+			return nil, fmt.Errorf("huffman: stream %d of %d bytes past the end of the block", i, size)
+		}
+		sizes[i] = int(size)
+		total += sizes[i]
+	}
+	sizes[interleavedStreams-1] = len(rest) - total
+
+	var streams [interleavedStreams]bitStream
+	var out [interleavedStreams][]byte
+	coded := make([]byte, count)
+	bounds := segments(len(coded))
+	for i := range streams {
+		streams[i].data, rest = rest[:sizes[i]], rest[sizes[i]:]
+		out[i] = coded[bounds[i]:bounds[i+1]]
+	}
+
+	table := z.table
+	s0, s1, s2, s3 := &streams[0], &streams[1], &streams[2], &streams[3]
+	o0, o1, o2, o3 := out[0], out[1], out[2], out[3]
+	// the last segment is the shortest
+	for i := range o3 {
+		o0[i] = s0.decode(table)
+		o1[i] = s1.decode(table)
+		o2[i] = s2.decode(table)
+		o3[i] = s3.decode(table)
+	}
+	for i := range streams {
+		for j := len(o3); j < len(out[i]); j++ {
+			out[i][j] = streams[i].decode(table)
+		}
+	}
+
+	for i, s := range streams {
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.read > 8*len(s.data) {
+			return nil, fmt.Errorf("huffman: stream %d ends early %w", i, io.ErrUnexpectedEOF)
+		}
+		if 8*len(s.data)-s.read >= 8 {
+			return nil, fmt.Errorf("huffman: stream %d has %d bytes after its symbols", i, (8*len(s.data)-s.read)/8)
+		}
+	}
+	return &decodedBlock{data: coded}, nil
+}
+
+// bitStream reads one of the bitstreams of an interleaved block, like a
+// BitReader with fewer checks: past its end it reads zeros, which the
+// decoder checks for once the block is decoded.
+type bitStream struct {
+	data  []byte
+	idx   int    // index of the next byte of data to load into acc
+	acc   uint64 // loaded bits, the next one to read in the highest bit
+	nbits uint8  // number of loaded bits in acc
+	read  int    // number of bits read
+	err   error
+}
+
+// refill loads whole bytes into acc, holding up to 56 bits, until it holds
+// more than 56 bits.
+func (s *bitStream) refill() {
+	if s.idx+8 <= len(s.data) {
+		s.acc |= binary.BigEndian.Uint64(s.data[s.idx:]) >> s.nbits
+		loaded := (64 - s.nbits) / 8
+		s.idx += int(loaded)
+		s.nbits += loaded * 8
+		return
+	}
+	for s.nbits <= 56 {
+		if s.idx < len(s.data) {
+			s.acc |= uint64(s.data[s.idx]) << (56 - s.nbits)
+		}
+		s.idx++
+		s.nbits += 8
+	}
+}
+
+func (s *bitStream) consume(n uint8) {
+	s.acc <<= n
+	s.nbits -= n
+	s.read += int(n)
+}
+
+// decode reads one symbol. An invalid code sets err.
+func (s *bitStream) decode(t *decodeTable) byte {
+	if s.nbits <= 56 {
+		s.refill()
+	}
+	for {
+		entry := t.entries[s.acc>>(64-t.bits)]
+		if entry.next != nil {
+			s.consume(t.bits)
+			if s.nbits <= 56 {
+				s.refill()
+			}
+			t = entry.next
+			continue
+		}
+		if entry.length == 0 {
+			s.err = errInvalidCode
+			return 0
+		}
+		s.consume(entry.length)
+		return byte(entry.symbol)
+	}
+}
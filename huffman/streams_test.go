@@ -0,0 +1,227 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSegments(t *testing.T) {
+	type test_case struct {
+		description string
+		n           int
+		expected    [interleavedStreams + 1]int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single symbol",
+			n:           1,
+			expected:    [interleavedStreams + 1]int{0, 1, 1, 1, 1},
+		},
+		{
+			description: "fewer symbols than streams",
+			n:           3,
+			expected:    [interleavedStreams + 1]int{0, 1, 2, 3, 3},
+		},
+		{
+			description: "multiple of 4",
+			n:           8,
+			expected:    [interleavedStreams + 1]int{0, 2, 4, 6, 8},
+		},
+		{
+			description: "shorter last segment",
+			n:           10,
+			expected:    [interleavedStreams + 1]int{0, 3, 6, 9, 10},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			got := segments(scenario.n)
+
+			if got != scenario.expected {
+				t.Fatalf(`Test %d Failed.
+				Got: %v,
+				Wanted: %v`, scenarioIdx, got, scenario.expected)
+			}
+		})
+	}
+}
+
+func TestInterleavedRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single byte",
+			data:        []byte("a"),
+		},
+		{
+			description: "fewer bytes than streams",
+			data:        []byte("abc"),
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 100_001),
+		},
+		{
+			description: "all bytes",
+			data:        randomData(100_002, 256, 51),
+		},
+		{
+			description: "codes longer than the decode table",
+			data:        fibonacciData(20),
+		},
+		{
+			description: "limited code length",
+			data:        fibonacciData(20),
+			encoder:     Encoder{MaxCodeLength: MinCodeLength},
+		},
+		{
+			description: "several blocks",
+			data:        randomData(3*MinBlockSize+17, 64, 52),
+			encoder:     Encoder{BlockSize: MinBlockSize},
+		},
+		{
+			description: "transforms",
+			data:        sourceText(),
+			encoder:     Encoder{Transforms: bzip2Transforms},
+		},
+		{
+			description: "parallel",
+			data:        sourceText(),
+			encoder:     Encoder{BlockSize: MinBlockSize, Concurrency: 4},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			e := scenario.encoder
+			e.Interleaved = true
+			if _, err := e.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			d := Decoder{Concurrency: e.Concurrency}
+			decoded, err := io.ReadAll(d.NewReader(iotest.HalfReader(&encoded)))
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v,
+				Wanted: %d bytes, <nil>`, scenarioIdx, len(decoded), err, len(scenario.data))
+			}
+		})
+	}
+}
+
+func TestInterleaved_ShouldFail(t *testing.T) {
+	for _, e := range []Encoder{
+		{Interleaved: true, Adaptive: true},
+		{Interleaved: true, Order1: true},
+		{Interleaved: true, Level: 1},
+		{Interleaved: true, Coder: CoderRange},
+		{Interleaved: true, Alphabet: AlphabetWords},
+		{Interleaved: true, Format: FormatGzip},
+	} {
+		if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with %+v: got err <nil>, wanted err != <nil>", e)
+		}
+	}
+
+	data := randomData(10_000, 16, 53)
+	var encoded bytes.Buffer
+	(&Encoder{Interleaved: true}).Encode(&encoded, data)
+
+	// 16 codes of 4 bits: the table of 16*7 bits and a run of 240 bytes
+	// without codes of 9 bits, padded, follows the block size and the
+	// symbol count, then the jump table of streams of about 1000 bytes
+	jump_idx := headerSize + 4 + 2 + 15
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte) []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "stream past the end of the block",
+			tamper: func(encoded []byte) []byte {
+				encoded[jump_idx+1] = 0x7f
+				return encoded
+			},
+		},
+		{
+			description: "shorter stream",
+			tamper: func(encoded []byte) []byte {
+				encoded[jump_idx] -= 1
+				return encoded
+			},
+		},
+		{
+			description: "longer stream",
+			tamper: func(encoded []byte) []byte {
+				encoded[jump_idx] += 1
+				return encoded
+			},
+		},
+		{
+			description: "first stream taking the rest of the block",
+			tamper: func(encoded []byte) []byte {
+				// the size of the second stream is read past the first one
+				rest := len(encoded) - 4 - trailerSize - (jump_idx + 2)
+				binary.PutUvarint(encoded[jump_idx:], uint64(rest))
+				return encoded
+			},
+		},
+		{
+			description: "truncated jump table",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize+3] = 2 + 15 + 1
+				return append(encoded[:jump_idx+1], 0x80, 0, 0, 0, 0)
+			},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := scenario.tamper(append([]byte{}, encoded.Bytes()...))
+
+			decoded, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			if err == nil && bytes.Equal(decoded, data) {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
+
+func BenchmarkInterleaved(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		for _, interleaved := range []bool{false, true} {
+			name := corpus.name + "/single"
+			if interleaved {
+				name = corpus.name + "/interleaved"
+			}
+			var encoded bytes.Buffer
+			size, _ := (&Encoder{Interleaved: interleaved}).Encode(&encoded, corpus.data)
+
+			b.Run(name, func(b *testing.B) {
+				b.SetBytes(int64(len(corpus.data)))
+				for i := 0; i < b.N; i++ {
+					io.Copy(io.Discard, NewReader(bytes.NewReader(encoded.Bytes())))
+				}
+				b.ReportMetric(float64(size)/float64(len(corpus.data)), "size/input")
+			})
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package huffman
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf8"
+)
+
+// Alphabet is how the bytes of a block are split into the symbols coded.
+type Alphabet uint8
+
+const (
+	// AlphabetBytes codes each byte as a symbol.
+	AlphabetBytes Alphabet = iota
+
+	// AlphabetUint16 codes each pair of bytes as a 16-bit symbol, a block of
+	// odd size ending with a single byte symbol.
+	AlphabetUint16
+
+	// AlphabetRunes codes each UTF-8 encoded rune as a symbol, bytes that
+	// aren't valid UTF-8 being symbols of their own.
+	AlphabetRunes
+
+	// AlphabetWords codes each run of non-whitespace bytes, and each run of
+	// ASCII whitespace between them, as a symbol, so that text is coded a
+	// word at a time.
+	AlphabetWords
+)
+
+func (a Alphabet) String() string {
+	switch a {
+	case AlphabetBytes:
+		return "bytes"
+	case AlphabetUint16:
+		return "uint16"
+	case AlphabetRunes:
+		return "runes"
+	case AlphabetWords:
+		return "words"
+	}
+	return fmt.Sprintf("Alphabet(%d)", uint8(a))
+}
+
+// symbolLength returns the length of the symbol data starts with.
+func (a Alphabet) symbolLength(data []byte) int {
+	switch a {
+	case AlphabetUint16:
+		return min(2, len(data))
+	case AlphabetRunes:
+		_, n := utf8.DecodeRune(data)
+		return n
+	case AlphabetWords:
+		space := isSpace(data[0])
+		n := 1
+		for n < len(data) && isSpace(data[n]) == space {
+			n++
+		}
+		return n
+	}
+	return 1
+}
+
+// split returns the symbols of data, in order.
+func (a Alphabet) split(data []byte) [][]byte {
+	var symbols [][]byte
+	for len(data) > 0 {
+		n := a.symbolLength(data)
+		symbols = append(symbols, data[:n])
+		data = data[n:]
+	}
+	return symbols
+}
+
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+var errDictionary = errors.New("huffman: invalid symbol dictionary")
+
+// encodeSymbolBlock writes data as a single block of the symbols of the
+// alphabet of e, without its size prefix. The symbol count is followed by
+// the dictionary of the symbols of the block: its size, then each symbol in
+// sorted order, as the length of the prefix it shares with the one before,
+// the length of the rest and the rest, all varints but the rest. The code
+// lengths table of the dictionary and the bitstream follow.
+// Returns the number of bytes written to w.
+func (e *Encoder) encodeSymbolBlock(w io.Writer, data []byte) (int, error) {
+	symbols := e.Alphabet.split(data)
+
+	// frequency of each symbol, then its index in the dictionary
+	index := make(map[string]int)
+	for _, s := range symbols {
+		index[string(s)]++
+	}
+	dictionary := make([]string, 0, len(index))
+	for s := range index {
+		dictionary = append(dictionary, s)
+	}
+	slices.Sort(dictionary)
+	freqs := make([]int, len(dictionary))
+	for i, s := range dictionary {
+		freqs[i] = index[s]
+		index[s] = i
+	}
+	lengths := huffmanLengths(freqs)
+	codes := canonicalCodes(lengths)
+
+	buf := binary.AppendUvarint(nil, uint64(len(symbols)))
+	buf = binary.AppendUvarint(buf, uint64(len(dictionary)))
+	prev := ""
+	for _, s := range dictionary {
+		shared := 0
+		for shared < min(len(prev), len(s)) && prev[shared] == s[shared] {
+			shared++
+		}
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = binary.AppendUvarint(buf, uint64(len(s)-shared))
+		buf = append(buf, s[shared:]...)
+		prev = s
+	}
+
+	bw := BitWriter{io_writer: w}
+	for _, b := range buf {
+		bw.WriteByte(b)
+	}
+	bw.writeLengths(lengths)
+	for _, s := range symbols {
+		bw.WriteCode(codes[index[string(s)]])
+	}
+	e.tree = nil
+	e.codes = CodeTable{}
+
+	return bw.FlushPadded()
+}
+
+// huffmanLengths returns the Huffman code length of each symbol of freqs,
+// none of them zero. Dictionaries of words are too large for trees of
+// Nodes, so lengths are computed in place on the sorted frequencies, as
+// Moffat and Katajainen do: a first pass pairs them into the parent of each
+// internal node, a second turns parents into depths, and a third counts the
+// leaves at each depth.
+func huffmanLengths(freqs []int) []uint8 {
+	n := len(freqs)
+	lengths := make([]uint8, n)
+	if n == 1 {
+		lengths[0] = 1
+		return lengths
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(freqs[a], freqs[b])
+	})
+	a := make([]int, n)
+	for i, symbol := range order {
+		a[i] = freqs[symbol]
+	}
+
+	a[0] += a[1]
+	root, leaf := 0, 2
+	for next := 1; next < n-1; next++ {
+		if leaf >= n || a[root] < a[leaf] {
+			a[next] = a[root]
+			a[root] = next
+			root++
+		} else {
+			a[next] = a[leaf]
+			leaf++
+		}
+		if leaf >= n || (root < next && a[root] < a[leaf]) {
+			a[next] += a[root]
+			a[root] = next
+			root++
+		} else {
+			a[next] += a[leaf]
+			leaf++
+		}
+	}
+
+	a[n-2] = 0
+	for next := n - 3; next >= 0; next-- {
+		a[next] = a[a[next]] + 1
+	}
+
+	available, used, depth := 1, 0, 0
+	root, next := n-2, n-1
+	for available > 0 {
+		for root >= 0 && a[root] == depth {
+			used++
+			root--
+		}
+		for available > used {
+			a[next] = depth
+			next--
+			available--
+		}
+		available = 2 * used
+		depth++
+		used = 0
+	}
+
+	for i, symbol := range order {
+		lengths[symbol] = uint8(a[i])
+	}
+	return lengths
+}
+
+// dictionaryBlock decodes a block of multi-byte symbols.
+type dictionaryBlock struct {
+	dictionary [][]byte     // symbols of the block
+	table      *decodeTable // decode table of the indices of the symbols
+	symbol     []byte       // bytes of the current symbol left to return
+	left       int          // bytes the block can still decode to
+	count      uint64       // number of symbols left in the block
+}
+
+// readDictionary reads the dictionary and the code lengths table of a block
+// of count multi-byte symbols.
+func (z *Reader) readDictionary(count uint64) (*dictionaryBlock, error) {
+	max_size := MaxBlockSize
+	if z.transforms != nil {
+		max_size = maxTransformedSize
+	}
+
+	size, err := binary.ReadUvarint(z.br)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dictionary %w", unexpected(err))
+	}
+	if size == 0 || size > count {
+		return nil, fmt.Errorf("%w: %d symbols for a block of %d", errDictionary, size, count)
+	}
+
+	var dictionary [][]byte
+	var prev []byte
+	total := 0
+	for i := uint64(0); i < size; i++ {
+		shared, err := binary.ReadUvarint(z.br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading dictionary %w", unexpected(err))
+		}
+		rest, err := binary.ReadUvarint(z.br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading dictionary %w", unexpected(err))
+		}
+		if shared > uint64(len(prev)) || rest == 0 || rest > uint64(max_size) || total+int(shared+rest) > max_size {
+			return nil, fmt.Errorf("%w: symbol %d of %d+%d bytes", errDictionary, i, shared, rest)
+		}
+
+		symbol := make([]byte, int(shared+rest))
+		copy(symbol, prev[:shared])
+		for j := int(shared); j < len(symbol); j++ {
+			if symbol[j], err = z.br.ReadByte(); err != nil {
+				return nil, fmt.Errorf("error reading dictionary %w", unexpected(err))
+			}
+		}
+		total += len(symbol)
+		dictionary = append(dictionary, symbol)
+		prev = symbol
+	}
+
+	lengths := make([]uint8, size)
+	if err := z.br.readLengths(lengths); err != nil {
+		return nil, fmt.Errorf("error reading code lengths %w", unexpected(err))
+	}
+	d := &dictionaryBlock{dictionary: dictionary, left: max_size, count: count}
+	if d.table, err = newCanonicalDecodeTable(lengths); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// decode returns the next byte of the block, decoding a symbol once the
+// bytes of the last one are over. Returns io.EOF at the end of the block.
+func (d *dictionaryBlock) decode(br *BitReader) (byte, error) {
+	if len(d.symbol) == 0 {
+		if d.count == 0 {
+			return 0, io.EOF
+		}
+		symbol, err := d.table.decodeSymbol(br)
+		if err != nil {
+			return 0, symbolError(err, d.count)
+		}
+		d.count--
+		d.symbol = d.dictionary[symbol]
+		if d.left -= len(d.symbol); d.left < 0 {
+			return 0, fmt.Errorf("%w: block decodes to more than its maximum size", errDictionary)
+		}
+	}
+	b := d.symbol[0]
+	d.symbol = d.symbol[1:]
+	return b, nil
+}
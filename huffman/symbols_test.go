@@ -0,0 +1,336 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// wordData returns size bytes of text made of words drawn from a small
+// vocabulary, some of them not ASCII, Zipf-distributed like natural
+// language.
+func wordData(size int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	words := []string{"the", "of", "and", "to", "in", "a", "is", "that", "for", "it", "as", "was", "with", "be", "by", "on", "not", "he", "this", "are",
+		"naïve", "café", "über", "façade", "日本語", "Ωmega", "straße", "año", "crème", "brûlée", "encyclopædia", "coöperate", "résumé", "smörgåsbord"}
+	zipf := rand.NewZipf(rng, 1.1, 2, uint64(len(words)-1))
+	separators := []string{" ", " ", " ", " ", " ", ", ", ".\n", "\n\n", "\t"}
+
+	data := make([]byte, 0, size+32)
+	for len(data) < size {
+		data = append(data, words[zipf.Uint64()]...)
+		data = append(data, separators[rng.Intn(len(separators))]...)
+	}
+	return data[:size]
+}
+
+var alphabets = []Alphabet{AlphabetUint16, AlphabetRunes, AlphabetWords}
+
+func TestHuffmanLengths(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "two bytes",
+			data:        []byte("abbb"),
+		},
+		{
+			description: "all bytes",
+			data:        randomData(100_000, 256, 37),
+		},
+		{
+			description: "deep tree",
+			data:        fibonacciData(20),
+		},
+		{
+			description: "source text",
+			data:        sourceText(),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			byte_freqs := byteFrequencies(scenario.data)
+			var freqs []int
+			var symbols []byte
+			for b, freq := range byte_freqs {
+				if freq != 0 {
+					freqs = append(freqs, freq)
+					symbols = append(symbols, byte(b))
+				}
+			}
+
+			lengths := huffmanLengths(freqs)
+			tree_lengths := CodeLengths(BuildTree(scenario.data))
+			cost, tree_cost := 0, 0
+			for i, length := range lengths {
+				cost += freqs[i] * int(length)
+				tree_cost += freqs[i] * int(tree_lengths[symbols[i]])
+			}
+
+			if err := checkCodeLengths(lengths); err != nil || cost != tree_cost {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bits, %v,
+				Wanted: %d bits, <nil>`, scenarioIdx, cost, err, tree_cost)
+			}
+		})
+	}
+}
+
+func TestAlphabetSplit(t *testing.T) {
+	type test_case struct {
+		description string
+		alphabet    Alphabet
+		data        string
+		expected    []string
+	}
+
+	test_cases := []test_case{
+		{
+			description: "odd pairs",
+			alphabet:    AlphabetUint16,
+			data:        "abcde",
+			expected:    []string{"ab", "cd", "e"},
+		},
+		{
+			description: "runes",
+			alphabet:    AlphabetRunes,
+			data:        "añ日\xffz",
+			expected:    []string{"a", "ñ", "日", "\xff", "z"},
+		},
+		{
+			description: "words",
+			alphabet:    AlphabetWords,
+			data:        "  the café,\n\tis open",
+			expected:    []string{"  ", "the", " ", "café,", "\n\t", "is", " ", "open"},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var got []string
+			for _, symbol := range scenario.alphabet.split([]byte(scenario.data)) {
+				got = append(got, string(symbol))
+			}
+
+			if len(got) != len(scenario.expected) {
+				t.Fatalf(`Test %d Failed.
+				Got: %q,
+				Wanted: %q`, scenarioIdx, got, scenario.expected)
+			}
+			for i := range got {
+				if got[i] != scenario.expected[i] {
+					t.Fatalf(`Test %d Failed.
+					Got: %q,
+					Wanted: %q`, scenarioIdx, got, scenario.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSymbolRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single symbol",
+			data:        []byte("a"),
+		},
+		{
+			description: "single repeating word",
+			data:        bytes.Repeat([]byte("word "), 10_000),
+		},
+		{
+			description: "random bytes",
+			data:        randomData(100_001, 256, 38),
+		},
+		{
+			description: "invalid UTF-8",
+			data:        append([]byte("añ\xff\xfe日"), randomData(10_000, 256, 39)...),
+		},
+		{
+			description: "words across blocks",
+			data:        wordData(3*MinBlockSize+17, 40),
+			encoder:     Encoder{BlockSize: MinBlockSize},
+		},
+		{
+			description: "source text",
+			data:        sourceText(),
+		},
+		{
+			description: "transforms",
+			data:        sourceText(),
+			encoder:     Encoder{Transforms: []Transform{TransformBWT}},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for _, alphabet := range alphabets {
+				var encoded bytes.Buffer
+				e := scenario.encoder
+				e.Alphabet = alphabet
+				if _, err := e.Encode(&encoded, scenario.data); err != nil {
+					t.Fatalf("Test %d Failed. %v encode error: %v", scenarioIdx, alphabet, err)
+				}
+
+				zr := NewReader(iotest.HalfReader(&encoded))
+				decoded, err := io.ReadAll(zr)
+
+				if err != nil || !bytes.Equal(decoded, scenario.data) || zr.Alphabet() != alphabet {
+					t.Fatalf(`Test %d Failed.
+					Got: %d bytes, %v, alphabet %v,
+					Wanted: %d bytes, <nil>, alphabet %v`, scenarioIdx, len(decoded), err, zr.Alphabet(), len(scenario.data), alphabet)
+				}
+			}
+		})
+	}
+}
+
+func TestSymbolGain(t *testing.T) {
+	data := wordData(DefaultBlockSize, 41)
+	bytes_size, _ := (&Encoder{}).Encode(io.Discard, data)
+	words_size, _ := (&Encoder{Alphabet: AlphabetWords}).Encode(io.Discard, data)
+	t.Logf("%d bytes: bytes %d bytes, words %d bytes (%.02f%%)", len(data), bytes_size, words_size, 100*float64(words_size)/float64(bytes_size))
+
+	if words_size >= bytes_size/2 {
+		t.Fatalf(`Test Gain Failed.
+		Got: %d bytes,
+		Wanted: less than half of %d bytes`, words_size, bytes_size)
+	}
+}
+
+func TestSymbol_ShouldFail(t *testing.T) {
+	for _, e := range []Encoder{
+		{Alphabet: AlphabetWords + 1},
+		{Alphabet: AlphabetWords, Adaptive: true},
+		{Alphabet: AlphabetRunes, Tables: 2},
+		{Alphabet: AlphabetUint16, Level: 1},
+		{Alphabet: AlphabetWords, Coder: CoderRANS},
+		{Alphabet: AlphabetWords, Format: FormatZlib},
+	} {
+		if _, err := e.Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with %+v: got err <nil>, wanted err != <nil>", e)
+		}
+	}
+
+	data := []byte("to be or not to be")
+	var encoded bytes.Buffer
+	(&Encoder{Alphabet: AlphabetWords}).Encode(&encoded, data)
+
+	// the alphabet follows the header, then the block size, the symbol
+	// count of 11 symbols and the dictionary of 5: " ", "be", "not", "or",
+	// "to", each a shared prefix, a length and the rest
+	dictionary_idx := headerSize + 1 + 4 + 1
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte) []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "bytes alphabet",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize] = byte(AlphabetBytes)
+				return encoded
+			},
+		},
+		{
+			description: "unknown alphabet",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize] = 9
+				return encoded
+			},
+		},
+		{
+			description: "empty dictionary",
+			tamper: func(encoded []byte) []byte {
+				encoded[dictionary_idx] = 0
+				return encoded
+			},
+		},
+		{
+			description: "dictionary larger than the block",
+			tamper: func(encoded []byte) []byte {
+				encoded[dictionary_idx] = 12
+				return encoded
+			},
+		},
+		{
+			description: "shared prefix longer than the symbol before",
+			tamper: func(encoded []byte) []byte {
+				encoded[dictionary_idx+1] = 1
+				return encoded
+			},
+		},
+		{
+			description: "empty symbol",
+			tamper: func(encoded []byte) []byte {
+				encoded[dictionary_idx+2] = 0
+				return encoded
+			},
+		},
+		{
+			description: "truncated block",
+			tamper: func(encoded []byte) []byte {
+				size := len(encoded) - headerSize - 1 - 8 - trailerSize
+				return append(encoded[:headerSize+1+4+size/2], 0, 0, 0, 0)
+			},
+		},
+		{
+			description: "flipped code bits",
+			tamper: func(encoded []byte) []byte {
+				encoded[len(encoded)-trailerSize-4-2] ^= 0x55
+				return encoded
+			},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := scenario.tamper(append([]byte{}, encoded.Bytes()...))
+
+			decoded, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			if err == nil && bytes.Equal(decoded, data) {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeSymbols(b *testing.B) {
+	data := wordData(DefaultBlockSize, 42)
+	for _, alphabet := range append([]Alphabet{AlphabetBytes}, alphabets...) {
+		e := Encoder{Alphabet: alphabet}
+		var encoded bytes.Buffer
+		size, _ := e.Encode(&encoded, data)
+
+		b.Run(alphabet.String()+"/encode", func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				e.Encode(io.Discard, data)
+			}
+			b.ReportMetric(float64(size)/float64(len(data)), "size/input")
+		})
+		b.Run(alphabet.String()+"/decode", func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				io.Copy(io.Discard, NewReader(bytes.NewReader(encoded.Bytes())))
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package huffman
+
+import (
+	"errors"
+	"io"
+)
+
+// number of bits the first level of a decodeTable is indexed with
+const decodeTableBits uint8 = 10
+
+var errInvalidCode = errors.New("huffman: code not in tree")
+
+// decodeTable decodes a symbol per lookup: it's indexed with the next bits
+// of the bitstream and gives the symbol whose code they start with. Codes
+// longer than the table's bits continue in a secondary table indexed with
+// the bits that follow.
+type decodeTable struct {
+	bits    uint8
+	entries []decodeEntry
+}
+
+type decodeEntry struct {
+	symbol uint32
+	length uint8        // length of the code, 0 when no code starts with these bits
+	next   *decodeTable // table of the codes longer than bits
+}
+
+// newDecodeTable builds the decode table of the codes of the tree.
+func newDecodeTable(root *Node) *decodeTable {
+	if root.IsLeaf() {
+		// single-noded tree, its one code is a 0 bit
+		entry := decodeEntry{symbol: uint32(root.ch), length: 1}
+		return &decodeTable{bits: 1, entries: []decodeEntry{entry, {}}}
+	}
+
+	t := &decodeTable{bits: uint8(min(treeHeight(root), int(decodeTableBits)))}
+	t.entries = make([]decodeEntry, 1<<t.bits)
+	t.add(root, 0, 0)
+	return t
+}
+
+// add fills the entries of the codes below n, reached from the root of the
+// table with the depth bits of prefix.
+func (t *decodeTable) add(n *Node, prefix int, depth uint8) {
+	if n == nil {
+		return
+	}
+
+	if n.IsLeaf() {
+		// every index starting with the code decodes to the symbol
+		free_bits := t.bits - depth
+		entry := decodeEntry{symbol: uint32(n.ch), length: depth}
+		for i := 0; i < 1<<free_bits; i++ {
+			t.entries[prefix<<free_bits|i] = entry
+		}
+		return
+	}
+
+	if depth == t.bits {
+		t.entries[prefix] = decodeEntry{next: newDecodeTable(n)}
+		return
+	}
+
+	t.add(n.Left, prefix<<1, depth+1)
+	t.add(n.Right, prefix<<1|1, depth+1)
+}
+
+// symbolCode is the code of a symbol of an alphabet of more than 256 symbols.
+type symbolCode struct {
+	symbol uint32
+	code   Code
+}
+
+// newCanonicalDecodeTable builds the decode table of the canonical codes of
+// lengths, for alphabets that don't fit in a byte.
+func newCanonicalDecodeTable(lengths []uint8) (*decodeTable, error) {
+	if err := checkCodeLengths(lengths); err != nil {
+		return nil, err
+	}
+
+	var codes []symbolCode
+	var longest uint8
+	for symbol, code := range canonicalCodes(lengths) {
+		if code.Length != 0 {
+			codes = append(codes, symbolCode{symbol: uint32(symbol), code: code})
+			longest = max(longest, code.Length)
+		}
+	}
+	return buildDecodeTable(codes, min(longest, decodeTableBits)), nil
+}
+
+// buildDecodeTable builds a table indexed with bits bits for codes, codes
+// longer than that continuing in secondary tables.
+func buildDecodeTable(codes []symbolCode, bits uint8) *decodeTable {
+	t := &decodeTable{bits: bits, entries: make([]decodeEntry, 1<<bits)}
+
+	longer := make(map[uint64][]symbolCode)
+	for _, c := range codes {
+		if c.code.Length <= bits {
+			free_bits := bits - c.code.Length
+			entry := decodeEntry{symbol: c.symbol, length: c.code.Length}
+			for i := uint64(0); i < 1<<free_bits; i++ {
+				t.entries[c.code.Value<<free_bits|i] = entry
+			}
+			continue
+		}
+
+		rest := c.code.Length - bits
+		prefix := c.code.Value >> rest
+		longer[prefix] = append(longer[prefix], symbolCode{
+			symbol: c.symbol,
+			code:   Code{Value: c.code.Value & (1<<rest - 1), Length: rest},
+		})
+	}
+
+	for prefix, group := range longer {
+		var longest uint8
+		for _, c := range group {
+			longest = max(longest, c.code.Length)
+		}
+		t.entries[prefix] = decodeEntry{next: buildDecodeTable(group, min(longest, decodeTableBits))}
+	}
+	return t
+}
+
+// decode reads one byte. Returns io.EOF if the bitstream is over.
+func (t *decodeTable) decode(br *BitReader) (byte, error) {
+	symbol, err := t.decodeSymbol(br)
+	return byte(symbol), err
+}
+
+// decodeSymbol reads one symbol. Returns io.EOF if the bitstream is over.
+func (t *decodeTable) decodeSymbol(br *BitReader) (int, error) {
+	table := t
+	for {
+		bits, available, err := br.PeekBits(table.bits)
+		if err != nil {
+			return 0, err
+		}
+		if available == 0 && table == t {
+			return 0, io.EOF
+		}
+
+		entry := table.entries[bits]
+		if entry.next != nil {
+			if available < table.bits {
+				return 0, io.ErrUnexpectedEOF
+			}
+			br.SkipBits(table.bits)
+			table = entry.next
+			continue
+		}
+
+		if entry.length == 0 {
+			return 0, errInvalidCode
+		}
+		if entry.length > available {
+			return 0, io.ErrUnexpectedEOF
+		}
+		br.SkipBits(entry.length)
+		return int(entry.symbol), nil
+	}
+}
+
+func treeHeight(n *Node) int {
+	if n == nil || n.IsLeaf() {
+		return 0
+	}
+	return 1 + max(treeHeight(n.Left), treeHeight(n.Right))
+}
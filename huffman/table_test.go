@@ -0,0 +1,235 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// walkTree decodes one symbol by following the tree bit by bit, the way
+// blocks were decoded before decode tables. Kept as a reference for
+// benchmarks.
+func walkTree(br *BitReader, root *Node) (byte, error) {
+	if root.IsLeaf() {
+		if _, err := br.ReadBit(); err != nil {
+			return 0, err
+		}
+		return root.ch, nil
+	}
+
+	current := root
+	for !current.IsLeaf() {
+		bit, err := br.ReadBit()
+		if err != nil {
+			if err == io.EOF && current != root {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if bit == 0 {
+			current = current.Left
+		} else {
+			current = current.Right
+		}
+		if current == nil {
+			return 0, errInvalidCode
+		}
+	}
+	return current.ch, nil
+}
+
+// encodeBits writes the codes of data followed by the trailing cursor byte.
+func encodeBits(codes CodeTable, data []byte) []byte {
+	var buf bytes.Buffer
+	w := NewBitWriter(&buf)
+	for _, b := range data {
+		w.WriteCode(codes[b])
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func TestDecodeTable(t *testing.T) {
+	type test_case struct {
+		description string
+		tree        *Node
+		data        []byte
+	}
+
+	deep_data := fibonacciData(25)
+	deep_tree, _ := treeFromCodeLengths(CodeLengths(BuildTree(deep_data)))
+
+	test_cases := []test_case{
+		{
+			description: "mock tree",
+			tree:        MOCK_TREE,
+			data:        []byte("ABCDEEDCBA"),
+		},
+		{
+			description: "single-noded tree",
+			tree:        &Node{ch: 'A'},
+			data:        []byte("AAAAAAAAA"),
+		},
+		{
+			description: "codes longer than the first level",
+			tree:        deep_tree,
+			data:        deep_data,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewBitWriter(&buf)
+			for _, b := range scenario.data {
+				var path []uint8
+				findPath(scenario.tree, b, &path)
+				w.WriteMultipleBits(path...)
+			}
+			w.Flush()
+
+			table := newDecodeTable(scenario.tree)
+			r := NewBitReader(buf.Bytes())
+			var decoded []byte
+			var err error
+			for {
+				var b byte
+				b, err = table.decode(r)
+				if err != nil {
+					break
+				}
+				decoded = append(decoded, b)
+			}
+
+			if err != io.EOF || !bytes.Equal(decoded, scenario.data) {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v,
+				Wanted: %d bytes, %v`, scenarioIdx, len(decoded), err, len(scenario.data), io.EOF)
+			}
+		})
+	}
+}
+
+func TestDecodeTable_ShouldFail(t *testing.T) {
+	// 'A'=0, 'B'=10, no code starts with 11
+	tree := &Node{Left: &Node{ch: 'A'}, Right: &Node{Left: &Node{ch: 'B'}}}
+
+	type test_case struct {
+		description string
+		data        []byte
+		expected    error
+	}
+
+	test_cases := []test_case{
+		{
+			description: "code not in tree",
+			data:        []byte{0b1100_0000, 0b0000_0010},
+			expected:    errInvalidCode,
+		},
+		{
+			description: "bitstream ends inside a code",
+			data:        []byte{0b0100_0000, 0b0000_0010},
+			expected:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			table := newDecodeTable(tree)
+			r := NewBitReader(scenario.data)
+			var err error
+			for err == nil {
+				_, err = table.decode(r)
+			}
+
+			if !errors.Is(err, scenario.expected) {
+				t.Fatalf(`Test %d Failed.
+				Got: err %v,
+				Wanted: err %v`, scenarioIdx, err, scenario.expected)
+			}
+		})
+	}
+}
+
+// findPath appends the bits leading to the leaf of b.
+func findPath(n *Node, b byte, path *[]uint8) bool {
+	if n == nil {
+		return false
+	}
+	if n.IsLeaf() {
+		if len(*path) == 0 {
+			// single-noded tree
+			*path = append(*path, 0)
+		}
+		return n.ch == b
+	}
+	*path = append(*path, 0)
+	if findPath(n.Left, b, path) {
+		return true
+	}
+	(*path)[len(*path)-1] = 1
+	if findPath(n.Right, b, path) {
+		return true
+	}
+	*path = (*path)[:len(*path)-1]
+	return false
+}
+
+// benchmark corpora: text-like data with a skewed distribution, and data
+// with long codes
+type benchmarkCorpus struct {
+	name string
+	data []byte
+}
+
+func benchmarkCorpora() []benchmarkCorpus {
+	return []benchmarkCorpus{
+		{"skewed", randomData(4<<20, 64, 5)},
+		{"uniform", randomData(4<<20, 256, 6)},
+		{"deep", fibonacciData(25)},
+	}
+}
+
+func BenchmarkDecodeTreeWalk(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			tree := BuildTree(corpus.data)
+			root, _ := treeFromCodeLengths(CodeLengths(tree))
+			encoded := encodeBits(NewCodeTable(tree), corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r := NewBitReader(encoded)
+				for {
+					if _, err := walkTree(r, root); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeTable(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			tree := BuildTree(corpus.data)
+			root, _ := treeFromCodeLengths(CodeLengths(tree))
+			encoded := encodeBits(NewCodeTable(tree), corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				table := newDecodeTable(root)
+				r := NewBitReader(encoded)
+				for {
+					if _, err := table.decode(r); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Transform is a reversible transform of the bytes of a block, applied
+// before coding to make their distribution easier to code.
+type Transform uint8
+
+const (
+	// TransformBWT is the Burrows-Wheeler transform, grouping bytes followed
+	// by the same context. It's stored as the row of the end of the block,
+	// a varint, then the last column of the sorted rotations.
+	TransformBWT Transform = iota + 1
+
+	// TransformMTF replaces each byte with its position in a list of the
+	// bytes most recently seen first, turning the clusters of the BWT into
+	// runs of small values.
+	TransformMTF
+
+	// TransformRLE codes runs of zeros, which MTF leaves plenty of: two
+	// zeros are followed by the number of zeros repeating them, up to 255.
+	TransformRLE
+)
+
+func (t Transform) String() string {
+	switch t {
+	case TransformBWT:
+		return "bwt"
+	case TransformMTF:
+		return "mtf"
+	case TransformRLE:
+		return "rle"
+	}
+	return fmt.Sprintf("Transform(%d)", uint8(t))
+}
+
+// maxTransformedSize is the largest a block can get once transformed: RLE
+// codes 2 zeros with 3 bytes, and BWT adds a varint.
+const maxTransformedSize int = MaxBlockSize*3/2 + binary.MaxVarintLen64
+
+// forward and inverse of each transform
+var transforms = [...]struct {
+	forward func(data []byte) []byte
+	inverse func(data []byte) ([]byte, error)
+}{
+	TransformBWT: {bwt, inverseBWT},
+	TransformMTF: {mtf, inverseMTF},
+	TransformRLE: {zeroRLE, inverseZeroRLE},
+}
+
+var errTransform = errors.New("huffman: invalid transformed block")
+
+// checkTransforms validates a chain of transforms: known ones, each at most
+// once.
+func checkTransforms(chain []Transform) error {
+	var seen [len(transforms)]bool
+	for _, t := range chain {
+		if t == 0 || int(t) >= len(transforms) {
+			return fmt.Errorf("huffman: unknown transform %d", t)
+		}
+		if seen[t] {
+			return fmt.Errorf("huffman: transform %v applied twice", t)
+		}
+		seen[t] = true
+	}
+	return nil
+}
+
+// applyTransforms applies chain to data, in order.
+func applyTransforms(chain []Transform, data []byte) []byte {
+	for _, t := range chain {
+		data = transforms[t].forward(data)
+	}
+	return data
+}
+
+// invertTransforms inverts chain on data, last transform first.
+func invertTransforms(chain []Transform, data []byte) ([]byte, error) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		var err error
+		if data, err = transforms[chain[i]].inverse(data); err != nil {
+			return nil, fmt.Errorf("%w: %v: %w", errTransform, chain[i], err)
+		}
+	}
+	if len(data) > MaxBlockSize {
+		return nil, fmt.Errorf("%w: %d bytes exceed the block size", errTransform, len(data))
+	}
+	return data, nil
+}
+
+// suffixArray returns the start of every suffix of data in sorted order,
+// by prefix doubling: suffixes sorted by their first k bytes are sorted by
+// their first 2k bytes from the ranks of both halves, until all ranks
+// differ. Each round is 2 counting sorts.
+func suffixArray(data []byte) []int32 {
+	n := len(data)
+	sa := make([]int32, n)
+	rank := make([]int32, n)
+	tmp := make([]int32, n)
+	counts := make([]int32, max(n, 256))
+	if n == 0 {
+		return sa
+	}
+
+	for i, b := range data {
+		rank[i] = int32(b)
+		counts[b]++
+	}
+	sortByRank(sa, identity(tmp), rank, counts)
+
+	for k := 1; ; k *= 2 {
+		// order by the second half: suffixes too short to have one first
+		pos := 0
+		for i := n - k; i < n; i++ {
+			if i >= 0 {
+				tmp[pos] = int32(i)
+				pos++
+			}
+		}
+		for _, s := range sa {
+			if int(s) >= k {
+				tmp[pos] = s - int32(k)
+				pos++
+			}
+		}
+
+		clear(counts)
+		for _, r := range rank {
+			counts[r]++
+		}
+		sortByRank(sa, tmp, rank, counts)
+
+		second := func(i int32) int32 {
+			if int(i)+k < n {
+				return rank[int(i)+k]
+			}
+			return -1
+		}
+		tmp[sa[0]] = 0
+		for j := 1; j < n; j++ {
+			prev, cur := sa[j-1], sa[j]
+			tmp[cur] = tmp[prev]
+			if rank[prev] != rank[cur] || second(prev) != second(cur) {
+				tmp[cur]++
+			}
+		}
+		rank, tmp = tmp, rank
+		if int(rank[sa[n-1]]) == n-1 {
+			return sa
+		}
+	}
+}
+
+// sortByRank stores order into sa stably sorted by rank, counts holding the
+// number of suffixes of each rank.
+func sortByRank(sa, order, rank, counts []int32) {
+	var start int32
+	for r, count := range counts {
+		counts[r] = start
+		start += count
+	}
+	for _, s := range order {
+		sa[counts[rank[s]]] = s
+		counts[rank[s]]++
+	}
+}
+
+func identity(order []int32) []int32 {
+	for i := range order {
+		order[i] = int32(i)
+	}
+	return order
+}
+
+// bwt returns the last column of the sorted rotations of data followed by
+// an end marker smaller than any byte, without the marker: the bytes
+// preceding each suffix in sorted order. It's prefixed with the row of the
+// marker.
+func bwt(data []byte) []byte {
+	if len(data) == 0 {
+		return binary.AppendUvarint(nil, 0)
+	}
+
+	last := make([]byte, 0, len(data))
+	// the first row is the marker alone, preceded by the last byte
+	last = append(last, data[len(data)-1])
+	primary := 0
+	for row, s := range suffixArray(data) {
+		if s == 0 {
+			primary = row + 1
+			continue
+		}
+		last = append(last, data[s-1])
+	}
+	return append(binary.AppendUvarint(make([]byte, 0, len(last)+binary.MaxVarintLen64), uint64(primary)), last...)
+}
+
+// inverseBWT rebuilds data from its last column, walking the rows from the
+// end of data backwards: the row of the rotation starting one byte earlier
+// is given by the rank of the byte among the bytes of the last column.
+func inverseBWT(transformed []byte) ([]byte, error) {
+	primary, n := binary.Uvarint(transformed)
+	if n <= 0 {
+		return nil, errors.New("no row of the end marker")
+	}
+	last := transformed[n:]
+	if len(last) == 0 {
+		if primary != 0 {
+			return nil, fmt.Errorf("end marker row %d of an empty block", primary)
+		}
+		return []byte{}, nil
+	}
+	if primary == 0 || primary > uint64(len(last)) {
+		return nil, fmt.Errorf("end marker row %d out of range [1, %d]", primary, len(last))
+	}
+
+	// first row of each byte in the sorted first column, after the marker
+	var starts [256]int
+	for _, b := range last {
+		starts[b]++
+	}
+	row := 1
+	for b, count := range starts {
+		starts[b] = row
+		row += count
+	}
+
+	// next[i] is the row preceding row i, i being an index of last, which
+	// skips the marker row
+	next := make([]int32, len(last))
+	for i, b := range last {
+		next[i] = int32(starts[b])
+		starts[b]++
+	}
+
+	data := make([]byte, len(last))
+	row = 0
+	for i := len(data) - 1; i >= 0; i-- {
+		if row == int(primary) {
+			return nil, fmt.Errorf("end marker reached %d bytes early", i+1)
+		}
+		index := row
+		if row > int(primary) {
+			index--
+		}
+		data[i] = last[index]
+		row = int(next[index])
+	}
+	return data, nil
+}
+
+// mtf replaces each byte with its index in a list of bytes, then moves it
+// to the front.
+func mtf(data []byte) []byte {
+	var list [256]byte
+	for i := range list {
+		list[i] = byte(i)
+	}
+	transformed := make([]byte, len(data))
+	for i, b := range data {
+		position := bytes.IndexByte(list[:], b)
+		copy(list[1:position+1], list[:position])
+		list[0] = b
+		transformed[i] = byte(position)
+	}
+	return transformed
+}
+
+func inverseMTF(transformed []byte) ([]byte, error) {
+	var list [256]byte
+	for i := range list {
+		list[i] = byte(i)
+	}
+	data := make([]byte, len(transformed))
+	for i, b := range transformed {
+		// an int, as position+1 overflows a byte at 255
+		position := int(b)
+		b = list[position]
+		copy(list[1:position+1], list[:position])
+		list[0] = b
+		data[i] = b
+	}
+	return data, nil
+}
+
+// zeroRLE codes each run of 2 zeros or more as 2 zeros followed by the
+// number of zeros left, runs of more than 257 zeros being split.
+func zeroRLE(data []byte) []byte {
+	transformed := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] != 0 {
+			transformed = append(transformed, data[i])
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(data) && data[i+run] == 0 && run < 2+255 {
+			run++
+		}
+		i += run
+		if run == 1 {
+			transformed = append(transformed, 0)
+			continue
+		}
+		transformed = append(transformed, 0, 0, byte(run-2))
+	}
+	return transformed
+}
+
+func inverseZeroRLE(transformed []byte) ([]byte, error) {
+	data := make([]byte, 0, len(transformed))
+	for i := 0; i < len(transformed); i++ {
+		if transformed[i] != 0 || i+1 == len(transformed) || transformed[i+1] != 0 {
+			data = append(data, transformed[i])
+			continue
+		}
+		if i+2 == len(transformed) {
+			return nil, fmt.Errorf("run of zeros without length %w", io.ErrUnexpectedEOF)
+		}
+		run := 2 + int(transformed[i+2])
+		if len(data)+run > maxTransformedSize {
+			return nil, fmt.Errorf("more than %d bytes", maxTransformedSize)
+		}
+		for ; run > 0; run-- {
+			data = append(data, 0)
+		}
+		i += 2
+	}
+	return data, nil
+}
@@ -0,0 +1,263 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+var bzip2Transforms = []Transform{TransformBWT, TransformMTF, TransformRLE}
+
+func TestSuffixArray(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("banana"),
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("abc"), 333),
+		randomData(5000, 4, 30),
+		sourceText()[:5000],
+	}
+
+	for inputIdx, data := range inputs {
+		sa := suffixArray(data)
+
+		expected := make([]int32, len(data))
+		for i := range expected {
+			expected[i] = int32(i)
+		}
+		slices.SortFunc(expected, func(a, b int32) int { return bytes.Compare(data[a:], data[b:]) })
+
+		if !slices.Equal(sa, expected) {
+			t.Fatalf(`Test %d Failed.
+			Got: %v,
+			Wanted: %v`, inputIdx, sa[:min(len(sa), 20)], expected[:min(len(expected), 20)])
+		}
+	}
+}
+
+// rankedBytes returns every byte in turn, then byte 0 again, which MTF
+// finds at the end of its list, at rank 255.
+func rankedBytes() []byte {
+	data := make([]byte, 257)
+	for i := 0; i < 256; i++ {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestTransforms(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0},
+		[]byte("banana"),
+		bytes.Repeat([]byte{0}, 1000),
+		bytes.Repeat([]byte("ab"), 500),
+		randomData(10_000, 256, 31),
+		rankedBytes(),
+		sourceText()[:50_000],
+	}
+
+	for _, transform := range bzip2Transforms {
+		for inputIdx, data := range inputs {
+			transformed := transforms[transform].forward(data)
+			inverted, err := transforms[transform].inverse(transformed)
+
+			if err != nil || !bytes.Equal(inverted, data) {
+				t.Fatalf(`Test %v %d Failed.
+				Got: %d bytes, %v,
+				Wanted: %d bytes, <nil>`, transform, inputIdx, len(inverted), err, len(data))
+			}
+		}
+	}
+
+	// sorted suffixes of abraca$: $, a$, abraca$, aca$, braca$, ca$, raca$,
+	// preceded by ac$raab
+	if transformed := bwt([]byte("abraca")); !bytes.Equal(transformed, append([]byte{2}, "acraab"...)) {
+		t.Fatalf("Test bwt Failed. Got: %q, Wanted: %q", transformed, append([]byte{2}, "acraab"...))
+	}
+}
+
+func TestTransformRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single byte",
+			data:        []byte("a"),
+			encoder:     Encoder{Transforms: bzip2Transforms},
+		},
+		{
+			description: "single repeating character",
+			data:        bytes.Repeat([]byte("a"), 100_000),
+			encoder:     Encoder{Transforms: bzip2Transforms},
+		},
+		{
+			description: "all bytes, several blocks",
+			data:        randomData(3*MinBlockSize+17, 256, 32),
+			encoder:     Encoder{BlockSize: MinBlockSize, Transforms: bzip2Transforms},
+		},
+		{
+			description: "zeros doubled by RLE",
+			data:        bytes.Repeat([]byte{0, 0, 1}, MinBlockSize),
+			encoder:     Encoder{BlockSize: MinBlockSize, Transforms: []Transform{TransformRLE}},
+		},
+		{
+			description: "MTF alone",
+			data:        sourceText(),
+			encoder:     Encoder{Transforms: []Transform{TransformMTF}},
+		},
+		{
+			description: "MTF of all bytes, up to rank 255",
+			data:        append(rankedBytes(), randomData(MinBlockSize, 256, 55)...),
+			encoder:     Encoder{Transforms: []Transform{TransformMTF}},
+		},
+		{
+			description: "order-1",
+			data:        sourceText(),
+			encoder:     Encoder{Order1: true, Transforms: bzip2Transforms},
+		},
+		{
+			description: "several tables",
+			data:        sourceText(),
+			encoder:     Encoder{Tables: MaxTables, Transforms: bzip2Transforms},
+		},
+		{
+			description: "LZ77 after BWT",
+			data:        logData(200_000, 33),
+			encoder:     Encoder{Level: 6, Transforms: []Transform{TransformBWT}},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var encoded bytes.Buffer
+			if _, err := scenario.encoder.Encode(&encoded, scenario.data); err != nil {
+				t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+			}
+
+			zr := NewReader(iotest.OneByteReader(&encoded))
+			decoded, err := io.ReadAll(zr)
+
+			if err != nil || !bytes.Equal(decoded, scenario.data) || !slices.Equal(zr.Transforms(), scenario.encoder.Transforms) {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes, %v, transforms %v,
+				Wanted: %d bytes, <nil>, transforms %v`, scenarioIdx, len(decoded), err, zr.Transforms(), len(scenario.data), scenario.encoder.Transforms)
+			}
+		})
+	}
+}
+
+func TestTransformGain(t *testing.T) {
+	data := sourceText()
+	plain, _ := (&Encoder{}).Encode(io.Discard, data)
+	transformed, _ := (&Encoder{Transforms: bzip2Transforms}).Encode(io.Discard, data)
+	t.Logf("%d bytes: Huffman only %d bytes, BWT+MTF+RLE %d bytes (%.02f%%)", len(data), plain, transformed, 100*float64(transformed)/float64(plain))
+
+	if transformed >= plain*3/4 {
+		t.Fatalf(`Test Transform Gain Failed.
+		Got: %d bytes,
+		Wanted: less than 3/4 of Huffman only %d bytes`, transformed, plain)
+	}
+}
+
+func TestTransform_ShouldFail(t *testing.T) {
+	for _, chain := range [][]Transform{{0}, {TransformRLE + 1}, {TransformMTF, TransformMTF}} {
+		if _, err := (&Encoder{Transforms: chain}).Encode(io.Discard, []byte("abc")); err == nil {
+			t.Fatalf("Encode with transforms %v: got err <nil>, wanted err != <nil>", chain)
+		}
+	}
+	if _, err := (&Encoder{Adaptive: true, Transforms: bzip2Transforms}).Encode(io.Discard, []byte("abc")); err == nil {
+		t.Fatalf("Encode adaptive with transforms: got err <nil>, wanted err != <nil>")
+	}
+
+	data := sourceText()[:10_000]
+	var encoded bytes.Buffer
+	(&Encoder{Transforms: bzip2Transforms}).Encode(&encoded, data)
+
+	type test_case struct {
+		description string
+		tamper      func(encoded []byte) []byte
+	}
+
+	test_cases := []test_case{
+		{
+			description: "no transform",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize] = 0
+				return encoded
+			},
+		},
+		{
+			description: "unknown transform",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize+1] = 9
+				return encoded
+			},
+		},
+		{
+			description: "transform twice",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize+2] = byte(TransformBWT)
+				return encoded
+			},
+		},
+		{
+			description: "truncated transforms",
+			tamper:      func(encoded []byte) []byte { return encoded[:headerSize+2] },
+		},
+		{
+			description: "transforms out of order",
+			tamper: func(encoded []byte) []byte {
+				encoded[headerSize+1], encoded[headerSize+3] = encoded[headerSize+3], encoded[headerSize+1]
+				return encoded
+			},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			tampered := scenario.tamper(append([]byte{}, encoded.Bytes()...))
+
+			decoded, err := io.ReadAll(NewReader(bytes.NewReader(tampered)))
+
+			if err == nil && bytes.Equal(decoded, data) {
+				t.Fatalf(`Test %d Failed.
+				Got: err <nil>,
+				Wanted: err != <nil>`, scenarioIdx)
+			}
+		})
+	}
+
+	for _, transformed := range [][]byte{{}, {1}, {2, 'a'}, {7, 'a', 'b'}} {
+		if _, err := inverseBWT(transformed); err == nil {
+			t.Fatalf("inverseBWT(%v): got err <nil>, wanted err != <nil>", transformed)
+		}
+	}
+	if _, err := inverseZeroRLE([]byte{1, 0, 0}); err == nil {
+		t.Fatalf("inverseZeroRLE of a run without length: got err <nil>, wanted err != <nil>")
+	}
+}
+
+func BenchmarkEncodeTransforms(b *testing.B) {
+	corpora := append(benchmarkCorpora(), benchmarkCorpus{"source", sourceText()})
+	for _, corpus := range corpora {
+		b.Run(corpus.name, func(b *testing.B) {
+			plain, _ := (&Encoder{}).Encode(io.Discard, corpus.data)
+
+			b.SetBytes(int64(len(corpus.data)))
+			b.ResetTimer()
+			transformed := 0
+			for i := 0; i < b.N; i++ {
+				transformed, _ = (&Encoder{Transforms: bzip2Transforms}).Encode(io.Discard, corpus.data)
+			}
+			b.ReportMetric(float64(transformed)/float64(plain), "size/plain")
+		})
+	}
+}
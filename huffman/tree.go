@@ -0,0 +1,119 @@
+package huffman
+
+import (
+	"fmt"
+	"sort"
+)
+
+const count int = 10
+
+// Node is a node of a Huffman tree. Leaves hold a symbol, internal nodes
+// hold the combined weight of their children.
+type Node struct {
+	Left   *Node
+	Right  *Node
+	ch     byte
+	weight int
+}
+
+// Symbol returns the byte held by a leaf node.
+func (n *Node) Symbol() byte {
+	return n.ch
+}
+
+// Weight returns the number of occurences of the node's symbols.
+// Trees read back from an encoded stream have no weights.
+func (n *Node) Weight() int {
+	return n.weight
+}
+
+// BuildTree builds the Huffman tree of the bytes in t, nil if t is empty.
+func BuildTree(t []byte) *Node {
+	return treeFromFrequencies(byteFrequencies(t))
+}
+
+// treeFromFrequencies builds the Huffman tree of bytes occuring freqs times.
+func treeFromFrequencies(freqs [256]int) *Node {
+	var nodes []Node
+	for b, freq := range freqs {
+		if freq > 0 {
+			nodes = append(nodes, Node{ch: byte(b), weight: freq})
+		}
+	}
+	return buildTree(nodes)
+}
+
+// buildTree merges leaf nodes into a Huffman tree with the two-queue method:
+// once leaves are sorted, the nodes merging the two lightest nodes come in
+// increasing weight, so they queue up after the leaves and the two lightest
+// nodes left are always at the front of the queues. Ties between leaves go
+// to the smaller byte, and ties between a leaf and a merged node go to the
+// leaf, which keeps the longest code as short as possible, so the tree only
+// depends on the weights.
+func buildTree(leaves []Node) *Node {
+	if len(leaves) == 0 {
+		return nil
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].weight == leaves[j].weight {
+			return leaves[i].ch < leaves[j].ch
+		}
+		return leaves[i].weight < leaves[j].weight
+	})
+
+	// leaves, then merged nodes, allocated at once so that the pointers of
+	// children stay valid
+	nodes := make([]Node, len(leaves), 2*len(leaves)-1)
+	copy(nodes, leaves)
+	leaf, merged := 0, len(leaves)
+	lightest := func() *Node {
+		if leaf < len(leaves) && (merged == len(nodes) || nodes[leaf].weight <= nodes[merged].weight) {
+			leaf++
+			return &nodes[leaf-1]
+		}
+		merged++
+		return &nodes[merged-1]
+	}
+
+	for len(nodes) < cap(nodes) {
+		a, b := lightest(), lightest()
+		nodes = append(nodes, Node{
+			weight: a.weight + b.weight,
+			Left:   b,
+			Right:  a,
+		})
+	}
+	return &nodes[len(nodes)-1]
+}
+
+func (n *Node) Display(space int) {
+	// Base case
+	if n == nil {
+		return
+	}
+	// Increase distance between levels
+	space += count
+
+	// Process right child first
+	n.Right.Display(space)
+
+	// Print current node after space
+	// count
+	fmt.Printf("\n")
+	for i := count; i < space; i++ {
+		fmt.Printf(" ")
+	}
+	if n.ch == 0 {
+		fmt.Printf("%d\n", n.weight)
+	} else {
+		fmt.Printf("\"%s\"-%d\n", string(n.ch), n.weight)
+	}
+
+	// Process left child
+	n.Left.Display(space)
+}
+
+// IsLeaf reports whether n has no children.
+func (n *Node) IsLeaf() bool {
+	return n.Left == nil && n.Right == nil
+}
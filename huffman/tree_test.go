@@ -0,0 +1,128 @@
+package huffman
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildTree(t *testing.T) {
+	type test_case struct {
+		description string
+		data        []byte
+		expected    map[byte]uint8
+	}
+
+	test_cases := []test_case{
+		{
+			description: "single byte",
+			data:        []byte("aaa"),
+			expected:    map[byte]uint8{'a': 1},
+		},
+		{
+			description: "ties between leaves go to the smaller byte",
+			data:        []byte("cba"),
+			expected:    map[byte]uint8{'a': 2, 'b': 2, 'c': 1},
+		},
+		{
+			description: "ties between a leaf and a merged node go to the leaf",
+			data:        []byte("abccdd"),
+			expected:    map[byte]uint8{'a': 2, 'b': 2, 'c': 2, 'd': 2},
+		},
+		{
+			description: "skewed weights",
+			data:        []byte("abbccccdddddddd"),
+			expected:    map[byte]uint8{'a': 3, 'b': 3, 'c': 2, 'd': 1},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			lengths := CodeLengths(BuildTree(scenario.data))
+
+			for b, length := range lengths {
+				if length != scenario.expected[byte(b)] {
+					t.Fatalf(`Test %d Failed. byte %q
+					Got: %d,
+					Wanted: %d`, scenarioIdx, b, length, scenario.expected[byte(b)])
+				}
+			}
+		})
+	}
+
+	if BuildTree(nil) != nil {
+		t.Fatalf("BuildTree of no bytes: got a tree, wanted <nil>")
+	}
+}
+
+func TestEncodeReproducible(t *testing.T) {
+	type test_case struct {
+		description string
+		encoder     Encoder
+	}
+
+	test_cases := []test_case{
+		{
+			description: "default",
+		},
+		{
+			description: "small blocks",
+			encoder:     Encoder{BlockSize: MinBlockSize},
+		},
+		{
+			description: "limited code length",
+			encoder:     Encoder{MaxCodeLength: MinCodeLength},
+		},
+		{
+			description: "multi-table",
+			encoder:     Encoder{Tables: 6},
+		},
+		{
+			description: "parallel",
+			encoder:     Encoder{BlockSize: MinBlockSize, Concurrency: 4},
+		},
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	// bytes of many equal weights, where ties decide the tree
+	data := append(sourceText(), randomData(MinBlockSize, 256, 54)...)
+	os.WriteFile(input, data, 0600)
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			var first [sha256.Size]byte
+			for i := 0; i < 5; i++ {
+				output := filepath.Join(dir, "output")
+				os.Remove(output)
+				e := scenario.encoder
+				if _, err := e.EncodeFile(input, output); err != nil {
+					t.Fatalf("Test %d Failed. encode error: %v", scenarioIdx, err)
+				}
+				encoded, _ := os.ReadFile(output)
+
+				hash := sha256.Sum256(encoded)
+				if i == 0 {
+					first = hash
+				}
+				if hash != first {
+					t.Fatalf(`Test %d Failed. encoding %d
+					Got: %x,
+					Wanted: %x`, scenarioIdx, i, hash, first)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuildTree(b *testing.B) {
+	for _, corpus := range benchmarkCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			b.SetBytes(int64(len(corpus.data)))
+			for i := 0; i < b.N; i++ {
+				BuildTree(corpus.data)
+			}
+		})
+	}
+}
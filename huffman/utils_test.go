@@ -0,0 +1,70 @@
+package huffman
+
+func AreByteArraysEqual(b1, b2 []byte) bool {
+	if len(b1) != len(b2) {
+		return false
+	}
+
+	for idx, b := range b1 {
+		if b != b2[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// writerState is what a BitWriter has been written so far: complete bytes
+// in buffer, and the cursor first bits of curr_byte.
+type writerState struct {
+	buffer    []byte
+	curr_byte byte
+	cursor    uint8
+}
+
+// toWriter returns a BitWriter in state s.
+func (s writerState) toWriter() BitWriter {
+	w := BitWriter{}
+	for _, b := range s.buffer {
+		w.WriteByte(b)
+	}
+	w.WriteBits(uint64(s.curr_byte>>(8-s.cursor)), s.cursor)
+	return w
+}
+
+// stateOf returns the state of w, whether its bits are in the buffer or the register.
+func stateOf(w BitWriter) writerState {
+	s := writerState{buffer: append([]byte{}, w.buffer...), cursor: w.nbits % 8}
+	nbits := w.nbits
+	for nbits >= 8 {
+		nbits -= 8
+		s.buffer = append(s.buffer, byte(w.acc>>nbits))
+	}
+	s.curr_byte = byte(w.acc << (8 - nbits))
+	return s
+}
+
+func (s writerState) equal(other writerState) bool {
+	return AreByteArraysEqual(s.buffer, other.buffer) && s.curr_byte == other.curr_byte && s.cursor == other.cursor
+}
+
+func areTreesEqual(n1, n2 *Node) bool {
+	if n1 == nil || n2 == nil {
+		if n1 == nil && n2 == nil {
+			return true
+		}
+		return false
+	}
+	if n1.ch != n2.ch {
+		return false
+	}
+	return areTreesEqual(n1.Left, n2.Left) && areTreesEqual(n1.Right, n2.Right)
+}
+
+// seek moves r to the bit cursor of byte idx.
+func (r *BitReader) seek(idx int, cursor uint8) {
+	r.idx = idx
+	r.acc = 0
+	r.nbits = 0
+	r.refill()
+	r.SkipBits(min(cursor, r.nbits))
+}
@@ -0,0 +1,206 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// size the buffer of a BitWriter may reach before it's written out to its io.Writer
+const writeChunkSize int = 32 * 1024
+
+// BitWriter packs bits most-significant first into bytes written to an io.Writer.
+//
+// Bits are accumulated in a 64-bit register and moved to the buffer 32 bits
+// at a time, so writing a code costs the same whatever its length.
+type BitWriter struct {
+	io_writer io.Writer
+	written   int   // number of bytes already written to io_writer
+	err       error // first error returned by io_writer
+	buffer    []byte
+	acc       uint64 // pending bits, the last one written in the lowest bit
+	nbits     uint8  // number of pending bits in acc, less than 32 between writes
+}
+
+// WriteBits appends the n lowest bits of value, most significant first.
+func (w *BitWriter) WriteBits(value uint64, n uint8) {
+	if n > 32 {
+		w.WriteBits(value>>32, n-32)
+		n = 32
+	}
+	value &= 1<<n - 1
+
+	w.acc = w.acc<<n | value
+	w.nbits += n
+	if w.nbits >= 32 {
+		w.nbits -= 32
+		w.buffer = binary.BigEndian.AppendUint32(w.buffer, uint32(w.acc>>w.nbits))
+		w.acc &= 1<<w.nbits - 1
+		w.drain()
+	}
+}
+
+// WriteCode appends a code from a CodeTable.
+func (w *BitWriter) WriteCode(c Code) {
+	w.WriteBits(c.Value, c.Length)
+}
+
+// WriteBit appends a single bit, 0 or 1.
+func (w *BitWriter) WriteBit(bit uint8) int {
+	w.WriteBits(uint64(bit), 1)
+	return 1
+}
+
+// WriteMultipleBits appends bits in order, one bit per element.
+func (w *BitWriter) WriteMultipleBits(bits ...uint8) {
+	for _, b := range bits {
+		w.WriteBit(b)
+	}
+}
+
+// WriteByte appends the 8 bits of b, whatever the current bit alignment.
+func (w *BitWriter) WriteByte(b byte) error {
+	w.WriteBits(uint64(b), 8)
+	return w.err
+}
+
+// drain writes the buffer out once it grows past writeChunkSize.
+// Without an io.Writer everything stays in the buffer until Flush.
+func (w *BitWriter) drain() {
+	if w.io_writer == nil || len(w.buffer) < writeChunkSize || w.err != nil {
+		return
+	}
+	n, err := w.io_writer.Write(w.buffer)
+	w.written += n
+	w.err = err
+	w.buffer = w.buffer[:0]
+}
+
+// WriteTree serializes the tree rooted at n: a 0 bit for an internal node
+// followed by its children, a 1 bit followed by the symbol for a leaf.
+// Returns the number of bits written.
+func (w *BitWriter) WriteTree(n *Node) uint32 {
+	if n.Left == nil && n.Right == nil {
+		size := uint32(w.WriteBit(1))
+		w.WriteByte(n.ch)
+		return size + 8
+	}
+
+	return uint32(w.WriteBit(0)) + w.WriteTree(n.Left) + w.WriteTree(n.Right)
+}
+
+// WriteCodeLengths serializes the code length of each of the 256 bytes: a 0
+// bit followed by a 6-bit length for a byte with a code, a 1 bit followed by
+// an 8-bit count minus one for a run of bytes without one.
+// Returns the number of bits written.
+func (w *BitWriter) WriteCodeLengths(lengths [256]uint8) uint32 {
+	return w.writeLengths(lengths[:])
+}
+
+// writeLengths serializes the code length of each symbol of an alphabet of
+// len(lengths) symbols, the way WriteCodeLengths does for bytes. Runs of
+// symbols without a code are cut every 256 symbols.
+// Returns the number of bits written.
+func (w *BitWriter) writeLengths(lengths []uint8) uint32 {
+	var size uint32
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			w.WriteBit(0)
+			w.WriteBits(uint64(lengths[i]), 6)
+			size += 7
+			i++
+			continue
+		}
+
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == 0 && run < 256 {
+			run++
+		}
+		w.WriteBit(1)
+		w.WriteBits(uint64(run-1), 8)
+		size += 9
+		i += run
+	}
+	return size
+}
+
+// NewBitWriter returns a BitWriter that writes to w.
+func NewBitWriter(w io.Writer) *BitWriter {
+	return &BitWriter{io_writer: w}
+}
+
+// FlushPadded pads the last byte with zeros and writes everything left to
+// the underlying io.Writer. Unlike Flush, the end of the bitstream isn't
+// recorded: its reader must know when to stop.
+// Returns the total number of bytes written since the last flush.
+func (w *BitWriter) FlushPadded() (int, error) {
+	w.align()
+	return w.write()
+}
+
+// FlushBytes writes the whole bytes written so far to the underlying
+// io.Writer, keeping the bits of an incomplete last byte pending.
+// Returns the total number of bytes written since the last flush.
+func (w *BitWriter) FlushBytes() (int, error) {
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buffer = append(w.buffer, byte(w.acc>>w.nbits))
+	}
+	w.acc &= 1<<w.nbits - 1
+	return w.write()
+}
+
+// align moves the pending bits to the buffer, the last byte padded with zeros.
+// Returns the number of bits used in the last byte, 0 if it's full.
+func (w *BitWriter) align() uint8 {
+	cursor := w.nbits % 8
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buffer = append(w.buffer, byte(w.acc>>w.nbits))
+	}
+	if cursor != 0 {
+		w.buffer = append(w.buffer, byte(w.acc<<(8-cursor)))
+	}
+	w.acc = 0
+	w.nbits = 0
+	return cursor
+}
+
+// Flush pads the last byte, appends the trailing cursor byte and writes
+// everything left to the underlying io.Writer.
+// Returns the total number of bytes written since the last flush.
+func (w *BitWriter) Flush() (int, error) {
+	cursor := w.align()
+
+	// we store the cursor in the last byte so that we can determine how many bits to read in the second-to-last byte
+	var lastByte byte = byte(cursor)
+	w.buffer = append(w.buffer, lastByte)
+
+	if __DEBUG__ {
+		fmt.Println("buffer:")
+		for _, b := range w.buffer {
+			fmt.Printf("%08b ", b)
+		}
+		fmt.Printf("\n")
+	}
+
+	return w.write()
+}
+
+// write writes the buffer to the underlying io.Writer.
+// Returns the total number of bytes written since the last flush.
+func (w *BitWriter) write() (int, error) {
+	if w.err != nil {
+		return w.written, w.err
+	}
+
+	n, err := w.io_writer.Write(w.buffer)
+	n += w.written
+	if err != nil {
+		return n, err
+	}
+
+	w.buffer = []byte{}
+	w.written = 0
+	return n, nil
+}
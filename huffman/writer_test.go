@@ -1,27 +1,30 @@
-package main
+package huffman
 
 import (
+	"bytes"
+	"io"
 	"testing"
+	"testing/iotest"
 )
 
 func TestWriteBit(t *testing.T) {
 	type test_case struct {
 		description     string
 		bit             uint8
-		writer          Writer
-		expected_writer Writer
+		writer          writerState
+		expected_writer writerState
 	}
 
 	test_cases := []test_case{
 		{
 			description: "write bit 1 on empty buffer, cursor at 0",
 			bit:         1,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1000_0000,
 				cursor:    1,
@@ -30,12 +33,12 @@ func TestWriteBit(t *testing.T) {
 		{
 			description: "write bit 0 on empty buffer, cursor at 0",
 			bit:         0,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    1,
@@ -44,12 +47,12 @@ func TestWriteBit(t *testing.T) {
 		{
 			description: "write bit 1 on empty buffer, cursor at 3",
 			bit:         1,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1000_0000,
 				cursor:    3,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1001_0000,
 				cursor:    4,
@@ -58,12 +61,12 @@ func TestWriteBit(t *testing.T) {
 		{
 			description: "write bit 0 on empty buffer, cursor at 7",
 			bit:         0,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1000_0000,
 				cursor:    7,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b1000_0000},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
@@ -72,12 +75,12 @@ func TestWriteBit(t *testing.T) {
 		{
 			description: "write bit 1 on non-empty buffer, cursor at 7",
 			bit:         1,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{0b1000_0000},
 				curr_byte: 0b1010_0010,
 				cursor:    7,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b1000_0000, 0b1010_0011},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
@@ -87,11 +90,12 @@ func TestWriteBit(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			scenario.writer.WriteBit(scenario.bit)
-			if !areWritersEqual(scenario.writer, scenario.expected_writer) {
+			w := scenario.writer.toWriter()
+			w.WriteBit(scenario.bit)
+			if got := stateOf(w); !got.equal(scenario.expected_writer) {
 				t.Fatalf(`Test %d Failed.
 				writer: %+v,
-				expected: %+v`, scenarioIdx, scenario.writer, scenario.expected_writer)
+				expected: %+v`, scenarioIdx, got, scenario.expected_writer)
 			}
 		})
 	}
@@ -101,20 +105,20 @@ func TestWriteMultipleBits(t *testing.T) {
 	type test_case struct {
 		description     string
 		bits            []uint8
-		writer          Writer
-		expected_writer Writer
+		writer          writerState
+		expected_writer writerState
 	}
 
 	test_cases := []test_case{
 		{
 			description: "write bits 101 on empty buffer, cursor at 0",
 			bits:        []uint8{1, 0, 1},
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1010_0000,
 				cursor:    3,
@@ -123,12 +127,12 @@ func TestWriteMultipleBits(t *testing.T) {
 		{
 			description: "write bits 0101 on empty buffer, cursor at 4",
 			bits:        []uint8{0, 1, 0, 1},
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    4,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b0000_0101},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
@@ -137,12 +141,12 @@ func TestWriteMultipleBits(t *testing.T) {
 		{
 			description: "write bits 110000011 on empty buffer, cursor at 3",
 			bits:        []uint8{1, 1, 0, 0, 0, 0, 0, 1, 1},
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b1000_0000,
 				cursor:    3,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b1001_1000},
 				curr_byte: 0b0011_0000,
 				cursor:    4,
@@ -152,11 +156,12 @@ func TestWriteMultipleBits(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			scenario.writer.WriteMultipleBits(scenario.bits...)
-			if !areWritersEqual(scenario.writer, scenario.expected_writer) {
+			w := scenario.writer.toWriter()
+			w.WriteMultipleBits(scenario.bits...)
+			if got := stateOf(w); !got.equal(scenario.expected_writer) {
 				t.Fatalf(`Test %d Failed.
 				writer: %+v,
-				expected: %+v`, scenarioIdx, scenario.writer, scenario.expected_writer)
+				expected: %+v`, scenarioIdx, got, scenario.expected_writer)
 			}
 		})
 	}
@@ -166,20 +171,20 @@ func TestWriteByte(t *testing.T) {
 	type test_case struct {
 		description     string
 		b               byte
-		writer          Writer
-		expected_writer Writer
+		writer          writerState
+		expected_writer writerState
 	}
 
 	test_cases := []test_case{
 		{
 			description: "write byte 01010001 on empty buffer, cursor at 0",
 			b:           0b01010001,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b01010001},
 				curr_byte: 0b0000_0000,
 				cursor:    0,
@@ -188,12 +193,12 @@ func TestWriteByte(t *testing.T) {
 		{
 			description: "write bits 01010001 on empty buffer, cursor at 4",
 			b:           0b01010001,
-			writer: Writer{
+			writer: writerState{
 				buffer:    []byte{},
 				curr_byte: 0b0100_0000,
 				cursor:    4,
 			},
-			expected_writer: Writer{
+			expected_writer: writerState{
 				buffer:    []byte{0b0100_0101},
 				curr_byte: 0b0001_0000,
 				cursor:    4,
@@ -203,29 +208,86 @@ func TestWriteByte(t *testing.T) {
 
 	for scenarioIdx, scenario := range test_cases {
 		t.Run(scenario.description, func(t *testing.T) {
-			scenario.writer.WriteByte(scenario.b)
-			if !areWritersEqual(scenario.writer, scenario.expected_writer) {
+			w := scenario.writer.toWriter()
+			w.WriteByte(scenario.b)
+			if got := stateOf(w); !got.equal(scenario.expected_writer) {
 				t.Fatalf(`Test %d Failed.
 				writer: %+v,
-				expected: %+v`, scenarioIdx, scenario.writer, scenario.expected_writer)
+				expected: %+v`, scenarioIdx, got, scenario.expected_writer)
 			}
 		})
 	}
 }
 
 func TestWriteTree(t *testing.T) {
-	w := Writer{}
+	w := BitWriter{}
 
 	tree_encoded_size := w.WriteTree(MOCK_TREE)
-	expected_writer := Writer{
+	expected_writer := writerState{
 		buffer:    []byte{0b00101000, 0b01110100, 0b00010101, 0b00010101, 0b01000100, 0b10100001},
 		cursor:    1,
 		curr_byte: 0b00000000,
 	}
 
-	if !areWritersEqual(w, expected_writer) || tree_encoded_size != uint32(MOCK_TREE_ENCODED_SIZE) {
+	if !stateOf(w).equal(expected_writer) || tree_encoded_size != uint32(MOCK_TREE_ENCODED_SIZE) {
 		t.Fatalf(`Test Write Tree Failed.
 		writer: %+v,
-		expected: %+v`, w, expected_writer)
+		expected: %+v`, stateOf(w), expected_writer)
+	}
+}
+
+func TestWriteBitsReadBits(t *testing.T) {
+	type value struct {
+		bits uint64
+		n    uint8
+	}
+
+	// values of every width, crossing the 32-bit words of the writer and
+	// the 64-bit register of the reader
+	var values []value
+	for i := 0; i < 2000; i++ {
+		n := uint8(i%56 + 1)
+		values = append(values, value{bits: uint64(i*2654435761) & (1<<n - 1), n: n})
+	}
+
+	var buf bytes.Buffer
+	w := NewBitWriter(&buf)
+	for _, v := range values {
+		w.WriteBits(v.bits, v.n)
+	}
+	w.Flush()
+
+	type test_case struct {
+		description string
+		reader      *BitReader
+	}
+
+	test_cases := []test_case{
+		{
+			description: "whole input in memory",
+			reader:      NewBitReader(buf.Bytes()),
+		},
+		{
+			description: "input read one byte at a time",
+			reader:      NewBitReaderFrom(iotest.OneByteReader(bytes.NewReader(buf.Bytes()))),
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			for i, v := range values {
+				bits, err := scenario.reader.ReadBits(v.n)
+				if err != nil || bits != v.bits {
+					t.Fatalf(`Test %d Failed at value %d.
+					Got: %b, %v,
+					Wanted: %b, <nil>`, scenarioIdx, i, bits, err, v.bits)
+				}
+			}
+			if _, err := scenario.reader.ReadBit(); err != io.EOF {
+				t.Fatalf(`Test %d Failed.
+				Got: err %v after the last value,
+				Wanted: err %v`, scenarioIdx, err, io.EOF)
+			}
+		})
 	}
 }
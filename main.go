@@ -1,41 +1,427 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
+	"time"
+
+	"huffman-coding/huffman"
+)
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1 // the command failed, such as on a corrupt or missing file
+	exitUsage = 2 // the command line is invalid
 )
 
+// command is a subcommand of the CLI.
+type command struct {
+	name        string
+	args        string // positional arguments, for the usage line
+	description string
+	run         func(flags *flag.FlagSet, args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "compress", args: "file", description: "Encodes file into file.huff, or the extension of -format.", run: compress},
+		{name: "decompress", args: "file", description: "Decodes file, written to file without its extension unless -o is given.", run: decompress},
+		{name: "info", args: "file...", description: "Prints the format, settings and sizes of encoded files.", run: info},
+		{name: "test", args: "file...", description: "Decodes files and checks their length and checksum, without writing them out.", run: test},
+		{name: "bench", args: "file", description: "Encodes and decodes file in memory, reporting the size and speed of both.", run: bench},
+	}
+}
+
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	decode := flag.Bool("d", false, "decode a huffman-encoded file")
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	help := false
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) == 1 {
+			usage(os.Stdout)
+			return exitOK
+		}
+		args, help = []string{args[1], "-h"}, true
+	}
 
-	inputFileName := flag.String("i", "", "name of inputFile")
-	outputFileName := flag.String("o", "", "name of outputFile")
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		if help {
+			flags.SetOutput(os.Stdout)
+		}
+		flags.Usage = func() {
+			fmt.Fprintf(flags.Output(), "usage: huff %s [flags] %s\n\n%s\n\nFlags:\n", c.name, c.args, c.description)
+			flags.PrintDefaults()
+		}
+		return c.run(flags, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "huff: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
 
-	flag.Parse()
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: huff <command> [flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nRun huff help <command> for the flags of a command.\n")
+}
 
-	if !*decode && !strings.HasSuffix(*outputFileName, ".huff") {
-		*outputFileName = *outputFileName + ".huff"
+// parse parses args into flags, expecting min_args positional arguments or
+// more, up to max_args unless it's negative. Returns the positional
+// arguments, and the exit code when the command can't run.
+func parse(flags *flag.FlagSet, args []string, min_args, max_args int) ([]string, int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, exitOK, false
+		}
+		return nil, exitUsage, false
+	}
+	if flags.NArg() < min_args || (max_args >= 0 && flags.NArg() > max_args) {
+		fmt.Fprintf(os.Stderr, "huff %s: wrong number of arguments\n", flags.Name())
+		flags.Usage()
+		return nil, exitUsage, false
 	}
+	return flags.Args(), exitOK, true
+}
+
+// fail prints err to stderr and returns the exit code of a failed command.
+func fail(flags *flag.FlagSet, err error) int {
+	fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+	return exitError
+}
+
+// encoderFlags defines the flags of the encoding settings on flags.
+// Returns a function building the Encoder they give once parsed, and
+// checking its settings.
+func encoderFlags(flags *flag.FlagSet) func() (huffman.Encoder, error) {
+	blockSize := flags.Int("b", huffman.DefaultBlockSize, "number of input bytes coded with the same tree")
+	maxCodeLength := flags.Int("l", 0, "maximum code length in bits, 0 for no limit")
+	adaptive := flags.Bool("a", false, "encode in a single pass with an adaptive tree, without blocks")
+	order1 := flags.Bool("c", false, "encode each byte with a table chosen by the byte before it")
+	tables := flags.Int("t", 0, "number of code tables per block, each 50-byte segment picking one")
+	level := flags.Int("z", 0, "LZ77 compression level, 1 (fastest) to 9 (strongest), 0 for Huffman coding only")
+	window := flags.Int("w", huffman.DefaultWindow, "how far back LZ77 matches can start, a power of two")
+	formatName := flags.String("format", "huff", "format: huff, deflate, gzip or zlib")
+	coderName := flags.String("e", "huffman", "entropy coder of the blocks: huffman, range or rans")
+	alphabetName := flags.String("s", "bytes", "symbols coded: bytes, uint16, runes or words")
+	interleaved := flags.Bool("n", false, "code each block as 4 bitstreams, decoded side by side")
+	concurrency := flags.Int("p", runtime.GOMAXPROCS(0), "number of blocks encoded at once")
+	transformNames := flags.String("x", "", "comma-separated transforms applied to each block before coding: bwt, mtf, rle")
 
-	h := Huffman{}
-	if *decode {
-		ratio, err := h.Decode(*inputFileName, *outputFileName)
+	return func() (huffman.Encoder, error) {
+		format, err := parseFormat(*formatName)
 		if err != nil {
-			fmt.Printf("error decoding file %s. Error: %v\n", *inputFileName, err)
-			os.Exit(1)
+			return huffman.Encoder{}, err
 		}
-		fmt.Printf("Written %s. Decompression Rate: %.02f%%.\n", *outputFileName, ratio * 100)
-	} else {
-		ratio, err := h.Encode(*inputFileName, *outputFileName)
+
+		coder := huffman.CoderHuffman
+		switch *coderName {
+		case "huffman":
+		case "range":
+			coder = huffman.CoderRange
+		case "rans":
+			coder = huffman.CoderRANS
+		default:
+			return huffman.Encoder{}, fmt.Errorf("unknown coder %s", *coderName)
+		}
+
+		alphabet := huffman.AlphabetBytes
+		switch *alphabetName {
+		case "bytes":
+		case "uint16":
+			alphabet = huffman.AlphabetUint16
+		case "runes":
+			alphabet = huffman.AlphabetRunes
+		case "words":
+			alphabet = huffman.AlphabetWords
+		default:
+			return huffman.Encoder{}, fmt.Errorf("unknown alphabet %s", *alphabetName)
+		}
+
+		var transforms []huffman.Transform
+		for _, name := range strings.Split(*transformNames, ",") {
+			switch name {
+			case "":
+			case "bwt":
+				transforms = append(transforms, huffman.TransformBWT)
+			case "mtf":
+				transforms = append(transforms, huffman.TransformMTF)
+			case "rle":
+				transforms = append(transforms, huffman.TransformRLE)
+			default:
+				return huffman.Encoder{}, fmt.Errorf("unknown transform %s", name)
+			}
+		}
+
+		e := huffman.Encoder{BlockSize: *blockSize, MaxCodeLength: *maxCodeLength, Adaptive: *adaptive, Order1: *order1, Tables: *tables, Level: *level, Window: *window, Format: format, Transforms: transforms, Coder: coder, Alphabet: alphabet, Interleaved: *interleaved, Concurrency: *concurrency}
+		return e, e.Check()
+	}
+}
+
+func parseFormat(name string) (huffman.Format, error) {
+	for _, format := range []huffman.Format{huffman.FormatHuffman, huffman.FormatDeflate, huffman.FormatGzip, huffman.FormatZlib} {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %s", name)
+}
+
+// extensions of the files of each format
+var extensions = [...]string{
+	huffman.FormatHuffman: ".huff",
+	huffman.FormatDeflate: ".deflate",
+	huffman.FormatGzip:    ".gz",
+	huffman.FormatZlib:    ".zz",
+}
+
+func compress(flags *flag.FlagSet, args []string) int {
+	encoder := encoderFlags(flags)
+	outputFileName := flags.String("o", "", "name of the output file, the input's with the extension of the format by default")
+	force := flags.Bool("f", false, "overwrite the output file if it exists")
+	args, code, ok := parse(flags, args, 1, 1)
+	if !ok {
+		return code
+	}
+
+	e, err := encoder()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+		return exitUsage
+	}
+	e.Overwrite = *force
+
+	output := *outputFileName
+	if output == "" {
+		output = args[0] + extensions[e.Format]
+	}
+	ratio, err := e.EncodeFile(args[0], output)
+	if err != nil {
+		return fail(flags, err)
+	}
+	fmt.Printf("Written %s. Compression Rate: %.02f%%.\n", output, ratio*100)
+	return exitOK
+}
+
+func decompress(flags *flag.FlagSet, args []string) int {
+	outputFileName := flags.String("o", "", "name of the output file, the input's without its extension by default")
+	force := flags.Bool("f", false, "overwrite the output file if it exists")
+	concurrency := flags.Int("p", runtime.GOMAXPROCS(0), "number of blocks decoded at once")
+	formatName := inputFormatFlag(flags)
+	args, code, ok := parse(flags, args, 1, 1)
+	if !ok {
+		return code
+	}
+	format, err := inputFormat(args[0], *formatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+		return exitUsage
+	}
+
+	output := *outputFileName
+	if output == "" {
+		for _, extension := range extensions {
+			if strings.HasSuffix(args[0], extension) {
+				output = strings.TrimSuffix(args[0], extension)
+			}
+		}
+		if output == "" {
+			fmt.Fprintf(os.Stderr, "huff %s: %s has no known extension, give the output file with -o\n", flags.Name(), args[0])
+			return exitUsage
+		}
+	}
+
+	d := huffman.Decoder{Concurrency: *concurrency, Format: format, Overwrite: *force}
+	ratio, err := d.DecodeFile(args[0], output)
+	if err != nil {
+		return fail(flags, err)
+	}
+	fmt.Printf("Written %s. Decompression Rate: %.02f%%.\n", output, ratio*100)
+	return exitOK
+}
+
+// inputFormatFlag defines the flag of the format of the input on flags.
+func inputFormatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", "", "format of the input, deflate for raw DEFLATE streams without a .deflate extension; others are recognized by their header")
+}
+
+// inputFormat returns the format name is read with: formatName if given,
+// else FormatDeflate for the .deflate extension, as raw DEFLATE streams
+// have no header to recognize them by.
+func inputFormat(name, formatName string) (huffman.Format, error) {
+	if formatName != "" {
+		return parseFormat(formatName)
+	}
+	if strings.HasSuffix(name, extensions[huffman.FormatDeflate]) {
+		return huffman.FormatDeflate, nil
+	}
+	return huffman.FormatHuffman, nil
+}
+
+// decodeFile decodes the file name, discarding its output.
+// Returns the Reader that decoded it, and the size of the decoding.
+func decodeFile(name string, formatName string, concurrency int) (*huffman.Reader, int64, error) {
+	format, err := inputFormat(name, formatName)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	zr := (&huffman.Decoder{Concurrency: concurrency, Format: format}).NewReader(f)
+	n, err := io.Copy(io.Discard, zr)
+	return zr, n, err
+}
+
+func info(flags *flag.FlagSet, args []string) int {
+	concurrency := flags.Int("p", runtime.GOMAXPROCS(0), "number of blocks decoded at once")
+	formatName := inputFormatFlag(flags)
+	args, code, ok := parse(flags, args, 1, -1)
+	if !ok {
+		return code
+	}
+	if _, err := inputFormat("", *formatName); err != nil {
+		fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+		return exitUsage
+	}
+
+	code = exitOK
+	for _, name := range args {
+		stat, err := os.Stat(name)
+		if err != nil {
+			code = fail(flags, err)
+			continue
+		}
+		zr, n, err := decodeFile(name, *formatName, *concurrency)
 		if err != nil {
-			fmt.Printf("error decoding file %s. Error: %v\n", *inputFileName, err)
-			os.Exit(1)
+			code = fail(flags, fmt.Errorf("%s: %w", name, err))
+			continue
 		}
-		fmt.Printf("Written %s. Compression Rate: %.02f%%.\n", *outputFileName, ratio * 100)
+
+		fmt.Printf("%s:\n", name)
+		fmt.Printf("  format:     %v\n", zr.Format())
+		if h := zr.Header(); h != nil {
+			transforms := make([]string, len(zr.Transforms()))
+			for i, t := range zr.Transforms() {
+				transforms[i] = t.String()
+			}
+			fmt.Printf("  version:    %d\n", h.Version)
+			fmt.Printf("  flags:      %v\n", h.Flags)
+			fmt.Printf("  coder:      %v\n", zr.Coder())
+			fmt.Printf("  alphabet:   %v\n", zr.Alphabet())
+			if len(transforms) > 0 {
+				fmt.Printf("  transforms: %s\n", strings.Join(transforms, ","))
+			}
+		}
+		fmt.Printf("  size:       %d\n", stat.Size())
+		fmt.Printf("  decoded:    %d\n", n)
+		if n > 0 {
+			fmt.Printf("  ratio:      %.02f%%\n", float64(stat.Size())/float64(n)*100)
+		}
+	}
+	return code
+}
+
+func test(flags *flag.FlagSet, args []string) int {
+	concurrency := flags.Int("p", runtime.GOMAXPROCS(0), "number of blocks decoded at once")
+	formatName := inputFormatFlag(flags)
+	args, code, ok := parse(flags, args, 1, -1)
+	if !ok {
+		return code
+	}
+	if _, err := inputFormat("", *formatName); err != nil {
+		fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+		return exitUsage
+	}
+
+	code = exitOK
+	for _, name := range args {
+		if _, _, err := decodeFile(name, *formatName, *concurrency); err != nil {
+			code = fail(flags, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+	return code
+}
+
+func bench(flags *flag.FlagSet, args []string) int {
+	encoder := encoderFlags(flags)
+	count := flags.Int("count", 3, "number of times the file is encoded and decoded, the fastest time being reported")
+	args, code, ok := parse(flags, args, 1, 1)
+	if !ok {
+		return code
 	}
 
-	os.Exit(0)
+	e, err := encoder()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "huff %s: %v\n", flags.Name(), err)
+		return exitUsage
+	}
+	if *count < 1 {
+		fmt.Fprintf(os.Stderr, "huff %s: count %d below 1\n", flags.Name(), *count)
+		return exitUsage
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fail(flags, err)
+	}
+
+	var encoded, decoded bytes.Buffer
+	encode_time, decode_time := time.Duration(1<<63-1), time.Duration(1<<63-1)
+	for i := 0; i < *count; i++ {
+		encoded.Reset()
+		start := time.Now()
+		if _, err := e.Encode(&encoded, data); err != nil {
+			return fail(flags, err)
+		}
+		encode_time = min(encode_time, time.Since(start))
+
+		decoded.Reset()
+		start = time.Now()
+		zr := (&huffman.Decoder{Concurrency: e.Concurrency, Format: e.Format}).NewReader(bytes.NewReader(encoded.Bytes()))
+		if _, err := io.Copy(&decoded, zr); err != nil {
+			return fail(flags, err)
+		}
+		decode_time = min(decode_time, time.Since(start))
+	}
+	if !bytes.Equal(decoded.Bytes(), data) {
+		return fail(flags, errors.New("decoding differs from the input"))
+	}
+
+	speed := func(d time.Duration) float64 {
+		return float64(len(data)) / d.Seconds() / (1 << 20)
+	}
+	fmt.Printf("%s: %d bytes\n", args[0], len(data))
+	fmt.Printf("  encoded: %d bytes", encoded.Len())
+	if len(data) > 0 {
+		fmt.Printf(", %.02f%%", float64(encoded.Len())/float64(len(data))*100)
+	}
+	fmt.Printf("\n  encode:  %v, %.1f MiB/s\n", encode_time, speed(encode_time))
+	fmt.Printf("  decode:  %v, %.1f MiB/s\n", decode_time, speed(decode_time))
+	return exitOK
 }
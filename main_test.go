@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeInput writes a file of text to dir, returning its name.
+func writeInput(t *testing.T, dir string) string {
+	name := filepath.Join(dir, "input.txt")
+	data := bytes.Repeat([]byte("huffman coding and decoding in golang\n"), 1000)
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir)
+	corrupt := filepath.Join(dir, "corrupt.huff")
+	os.WriteFile(corrupt, []byte("HUFF\x02\x00\x01 not blocks"), 0600)
+
+	type test_case struct {
+		description string
+		args        []string
+		expected    int
+	}
+
+	test_cases := []test_case{
+		{
+			description: "help",
+			args:        []string{"help"},
+			expected:    exitOK,
+		},
+		{
+			description: "help of a command",
+			args:        []string{"help", "compress"},
+			expected:    exitOK,
+		},
+		{
+			description: "compress",
+			args:        []string{"compress", "-o", filepath.Join(dir, "output.huff"), input},
+			expected:    exitOK,
+		},
+		{
+			description: "test",
+			args:        []string{"test", filepath.Join(dir, "output.huff")},
+			expected:    exitOK,
+		},
+		{
+			description: "info",
+			args:        []string{"info", filepath.Join(dir, "output.huff")},
+			expected:    exitOK,
+		},
+		{
+			description: "bench",
+			args:        []string{"bench", "-count", "1", "-z", "6", input},
+			expected:    exitOK,
+		},
+		{
+			description: "test of a corrupt file",
+			args:        []string{"test", filepath.Join(dir, "output.huff"), corrupt},
+			expected:    exitError,
+		},
+		{
+			description: "decompress a corrupt file",
+			args:        []string{"decompress", corrupt},
+			expected:    exitError,
+		},
+		{
+			description: "info of a file that isn't encoded",
+			args:        []string{"info", input},
+			expected:    exitError,
+		},
+		{
+			description: "missing file",
+			args:        []string{"compress", filepath.Join(dir, "missing")},
+			expected:    exitError,
+		},
+		{
+			description: "no command",
+			args:        []string{},
+			expected:    exitUsage,
+		},
+		{
+			description: "unknown command",
+			args:        []string{"squeeze", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "unknown flag",
+			args:        []string{"compress", "-q", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "missing argument",
+			args:        []string{"compress"},
+			expected:    exitUsage,
+		},
+		{
+			description: "too many arguments",
+			args:        []string{"decompress", input, input},
+			expected:    exitUsage,
+		},
+		{
+			description: "unknown coder",
+			args:        []string{"compress", "-e", "arithmetic", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "block size out of range",
+			args:        []string{"compress", "-b", "10", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "code length limit out of range",
+			args:        []string{"compress", "-l", "3", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "table count out of range",
+			args:        []string{"compress", "-t", "9", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "level out of range",
+			args:        []string{"compress", "-z", "12", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "window out of range",
+			args:        []string{"bench", "-w", "1000", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "negative concurrency",
+			args:        []string{"compress", "-p", "-1", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "adaptive and order-1 modes",
+			args:        []string{"compress", "-a", "-c", input},
+			expected:    exitUsage,
+		},
+		{
+			description: "unknown input format",
+			args:        []string{"test", "-format", "zip", corrupt},
+			expected:    exitUsage,
+		},
+		{
+			description: "output name without a known extension",
+			args:        []string{"decompress", input},
+			expected:    exitUsage,
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			got := run(scenario.args)
+
+			if got != scenario.expected {
+				t.Fatalf(`Test %d Failed. %q
+				Got: exit code %d,
+				Wanted: exit code %d`, scenarioIdx, scenario.args, got, scenario.expected)
+			}
+		})
+	}
+}
+
+func TestRunHelpOutput(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	type test_case struct {
+		description string
+		args        []string
+		stdout      bool // whether the usage goes to stdout rather than stderr
+	}
+
+	test_cases := []test_case{
+		{
+			description: "help",
+			args:        []string{"help"},
+			stdout:      true,
+		},
+		{
+			description: "help of a command",
+			args:        []string{"help", "compress"},
+			stdout:      true,
+		},
+		{
+			description: "missing argument",
+			args:        []string{"compress"},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			os.Stdout, _ = os.Create(filepath.Join(dir, "stdout"))
+			os.Stderr, _ = os.Create(filepath.Join(dir, "stderr"))
+			run(scenario.args)
+			os.Stdout.Close()
+			os.Stderr.Close()
+
+			out, _ := os.ReadFile(filepath.Join(dir, "stdout"))
+			errs, _ := os.ReadFile(filepath.Join(dir, "stderr"))
+			if got := bytes.Contains(out, []byte("usage: huff")); got != scenario.stdout || bytes.Contains(errs, []byte("usage: huff")) == scenario.stdout {
+				t.Fatalf(`Test %d Failed.
+				Got: stdout %q, stderr %q,
+				Wanted: the usage on stdout %v`, scenarioIdx, out, errs, scenario.stdout)
+			}
+		})
+	}
+}
+
+func TestRunOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	input := writeInput(t, dir)
+	output := input + ".huff"
+	os.WriteFile(output, []byte("kept"), 0600)
+
+	if code := run([]string{"compress", input}); code != exitError {
+		t.Fatalf(`Test Existing Output Failed.
+		Got: exit code %d,
+		Wanted: exit code %d`, code, exitError)
+	}
+	if kept, _ := os.ReadFile(output); string(kept) != "kept" {
+		t.Fatalf("Test Existing Output Failed. the output was changed to %d bytes", len(kept))
+	}
+
+	if code := run([]string{"compress", "-f", input}); code != exitOK {
+		t.Fatalf(`Test Overwrite Failed.
+		Got: exit code %d,
+		Wanted: exit code %d`, code, exitOK)
+	}
+	if code := run([]string{"test", output}); code != exitOK {
+		t.Fatalf("Test Overwrite Failed. the output doesn't decode, exit code %d", code)
+	}
+}
+
+func TestRunRoundTrip(t *testing.T) {
+	type test_case struct {
+		description string
+		args        []string
+	}
+
+	test_cases := []test_case{
+		{
+			description: "huff",
+			args:        []string{"-format", "huff"},
+		},
+		{
+			description: "deflate",
+			args:        []string{"-format", "deflate", "-z", "6"},
+		},
+		{
+			description: "gzip",
+			args:        []string{"-format", "gzip", "-z", "6"},
+		},
+		{
+			description: "zlib",
+			args:        []string{"-format", "zlib"},
+		},
+		{
+			description: "transforms and rANS",
+			args:        []string{"-x", "bwt,mtf,rle", "-e", "rans"},
+		},
+	}
+
+	for scenarioIdx, scenario := range test_cases {
+		t.Run(scenario.description, func(t *testing.T) {
+			dir := t.TempDir()
+			input := writeInput(t, dir)
+			data, _ := os.ReadFile(input)
+
+			if code := run(append(append([]string{"compress"}, scenario.args...), input)); code != exitOK {
+				t.Fatalf("Test %d Failed. compress exit code %d", scenarioIdx, code)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 2 {
+				t.Fatalf("Test %d Failed. %d files, wanted the input and its encoding", scenarioIdx, len(entries))
+			}
+			encoded := filepath.Join(dir, entries[0].Name())
+			if encoded == input {
+				encoded = filepath.Join(dir, entries[1].Name())
+			}
+
+			for _, command := range []string{"info", "test"} {
+				if code := run([]string{command, encoded}); code != exitOK {
+					t.Fatalf("Test %d Failed. %s exit code %d", scenarioIdx, command, code)
+				}
+			}
+
+			os.Remove(input)
+			if code := run([]string{"decompress", encoded}); code != exitOK {
+				t.Fatalf("Test %d Failed. decompress exit code %d", scenarioIdx, code)
+			}
+			decoded, _ := os.ReadFile(input)
+			if !bytes.Equal(decoded, data) {
+				t.Fatalf(`Test %d Failed.
+				Got: %d bytes,
+				Wanted: %d bytes`, scenarioIdx, len(decoded), len(data))
+			}
+		})
+	}
+}